@@ -0,0 +1,44 @@
+package cashout
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+var amountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// ValidAmount reports whether s is a decimal amount ParseAmount accepts with
+// enough decimals.
+func ValidAmount(s string) bool {
+	return amountPattern.MatchString(s)
+}
+
+// ParseAmount parses a decimal amount of whole tokens with the given number
+// of decimals into smallest units, exactly.
+func ParseAmount(s string, decimals int) (*big.Int, error) {
+	if !amountPattern.MatchString(s) {
+		return nil, fmt.Errorf("invalid amount %q", s)
+	}
+	r, _ := new(big.Rat).SetString(s)
+	r.Mul(r, new(big.Rat).SetInt(pow10(decimals)))
+	if !r.IsInt() {
+		return nil, fmt.Errorf("amount %q has more than %d decimals", s, decimals)
+	}
+	return new(big.Int).Set(r.Num()), nil
+}
+
+// FormatAmount formats an amount in smallest units as an exact decimal
+// string of whole tokens without trailing zeros.
+func FormatAmount(amount *big.Int, decimals int) string {
+	s := new(big.Rat).SetFrac(amount, pow10(decimals)).FloatString(decimals)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,67 @@
+package cashout
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		s        string
+		decimals int
+		want     string
+		err      bool
+	}{
+		{s: "10000", decimals: 4, want: "100000000"},
+		{s: "0.5", decimals: 4, want: "5000"},
+		{s: "0.0001", decimals: 4, want: "1"},
+		{s: "0", decimals: 18, want: "0"},
+		{s: "1.50", decimals: 1, want: "15"},
+		{s: "123456789012345678901234567890", decimals: 18, want: "123456789012345678901234567890000000000000000000"},
+		{s: "0.00001", decimals: 4, err: true},
+		{s: "-1", decimals: 4, err: true},
+		{s: "1e3", decimals: 4, err: true},
+		{s: ".5", decimals: 4, err: true},
+		{s: "", decimals: 4, err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAmount(tt.s, tt.decimals)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseAmount(%q, %d) = %v, want an error", tt.s, tt.decimals, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseAmount(%q, %d): %v", tt.s, tt.decimals, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("ParseAmount(%q, %d) = %v, want %s", tt.s, tt.decimals, got, tt.want)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		amount   string
+		decimals int
+		want     string
+	}{
+		{amount: "100000000", decimals: 4, want: "10000"},
+		{amount: "5000", decimals: 4, want: "0.5"},
+		{amount: "1", decimals: 18, want: "0.000000000000000001"},
+		{amount: "0", decimals: 4, want: "0"},
+		{amount: "12345", decimals: 0, want: "12345"},
+	}
+	for _, tt := range tests {
+		amount, _ := new(big.Int).SetString(tt.amount, 10)
+		if got := FormatAmount(amount, tt.decimals); got != tt.want {
+			t.Errorf("FormatAmount(%s, %d) = %s, want %s", tt.amount, tt.decimals, got, tt.want)
+		}
+		parsed, err := ParseAmount(tt.want, tt.decimals)
+		if err != nil || parsed.Cmp(amount) != 0 {
+			t.Errorf("ParseAmount(%q, %d) = %v, %v, want %s", tt.want, tt.decimals, parsed, err, tt.amount)
+		}
+	}
+}
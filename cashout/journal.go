@@ -0,0 +1,118 @@
+package cashout
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zhaozilong88/cashout/eth"
+	"github.com/zhaozilong88/cashout/ledger"
+)
+
+var (
+	errDropped   = errors.New("transaction dropped before it was mined")
+	errCancelled = errors.New("cashout cancelled")
+)
+
+// journal returns the hook that writes the signed cashCheque transaction of
+// attempt to the journal before it is broadcast. If the process dies after
+// that point, reconcile finds the transaction on the next run instead of
+// sending a second one for the same cheque.
+func (s *Service) journal(attempt *ledger.Attempt) eth.BeforeSend {
+	return func(tx *types.Transaction) error {
+		attempt.TxHash = tx.Hash().Hex()
+		s.record(attempt, ledger.StatusPending, nil)
+		if s.ledger == nil {
+			return nil
+		}
+		return s.ledger.Journal(&ledger.Entry{
+			Address:          attempt.Address,
+			CumulativePayout: attempt.Amount,
+			TxHash:           attempt.TxHash,
+			AttemptID:        attempt.ID,
+		})
+	}
+}
+
+// resolve removes the journal entry of an attempt whose transaction is final.
+func (s *Service) resolve(attempt *ledger.Attempt) {
+	if s.ledger == nil {
+		return
+	}
+	if err := s.ledger.Resolve(attempt.Address, attempt.Amount); err != nil {
+		s.logf("failed to write journal, %v\n", err)
+	}
+}
+
+// reconcile checks every journaled transaction against the chain. Mined and
+// dropped transactions are resolved and their attempts updated; transactions
+// still in the mempool stay journaled so their addresses are skipped.
+func (s *Service) reconcile(ctx context.Context) {
+	if s.ledger == nil {
+		return
+	}
+	entries, err := s.ledger.Pending("")
+	if err != nil {
+		s.logf("failed to read journal, %v\n", err)
+		return
+	}
+	for _, e := range entries {
+		state, receipt, err := s.entryState(ctx, e)
+		if err != nil {
+			s.logf("failed to check journaled cashout %s, %v\n", e.TxHash, err)
+			continue
+		}
+		if state == eth.TxPending {
+			continue
+		}
+
+		attempt, err := s.ledger.Attempt(e.AttemptID)
+		if err != nil || attempt == nil {
+			attempt = &ledger.Attempt{Address: e.Address, Amount: e.CumulativePayout}
+		}
+		attempt.TxHash = e.TxHash
+		switch {
+		case state == eth.TxUnknown:
+			// the dropped nonce is free again
+			s.chain.ResyncNonce(common.HexToAddress(e.Address))
+			s.record(attempt, ledger.StatusFailed, errDropped)
+		case receipt.TxHash.Hex() == e.CancelTx:
+			attempt.TxHash = e.CancelTx
+			attempt.GasUsed = receipt.GasUsed
+			s.record(attempt, ledger.StatusFailed, errCancelled)
+		case receipt.Status == types.ReceiptStatusSuccessful:
+			attempt.TxHash = receipt.TxHash.Hex()
+			attempt.GasUsed = receipt.GasUsed
+			s.record(attempt, ledger.StatusSuccess, nil)
+		default:
+			attempt.TxHash = receipt.TxHash.Hex()
+			attempt.GasUsed = receipt.GasUsed
+			s.record(attempt, ledger.StatusFailed, eth.ErrReverted)
+		}
+		s.resolve(attempt)
+		s.logf("%s journaled cashout %s %s\n", attempt.Address, attempt.TxHash, attempt.Status)
+	}
+}
+
+// entryState returns the state of a journal entry: mined with the receipt of
+// whichever of its transactions made it into a block, pending while any is
+// still in the mempool, unknown otherwise.
+func (s *Service) entryState(ctx context.Context, e *ledger.Entry) (eth.TxState, *types.Receipt, error) {
+	result := eth.TxUnknown
+	for _, hash := range e.Hashes() {
+		var state eth.TxState
+		var receipt *types.Receipt
+		var err error
+		s.rpcLimit.do(func() { state, receipt, err = s.chain.TxState(ctx, common.HexToHash(hash)) })
+		if err != nil {
+			return eth.TxUnknown, nil, err
+		}
+		if state == eth.TxMined {
+			return state, receipt, nil
+		}
+		if state == eth.TxPending {
+			result = eth.TxPending
+		}
+	}
+	return result, nil, nil
+}
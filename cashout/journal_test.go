@@ -0,0 +1,119 @@
+package cashout
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zhaozilong88/cashout/eth"
+	"github.com/zhaozilong88/cashout/ledger"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// txStates is a Chain that only knows the state of transactions; the hashes
+// it has no receipt or pending flag for are unknown.
+type txStates struct {
+	Chain
+	receipts map[common.Hash]*types.Receipt
+	pending  map[common.Hash]bool
+	resynced []common.Address
+}
+
+func (c *txStates) TxState(ctx context.Context, hash common.Hash) (eth.TxState, *types.Receipt, error) {
+	if r, ok := c.receipts[hash]; ok {
+		return eth.TxMined, r, nil
+	}
+	if c.pending[hash] {
+		return eth.TxPending, nil, nil
+	}
+	return eth.TxUnknown, nil, nil
+}
+
+func (c *txStates) ResyncNonce(addr common.Address) {
+	c.resynced = append(c.resynced, addr)
+}
+
+func TestReconcile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cashout-journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := ledger.Open(filepath.Join(dir, "cashout.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	hash := func(i byte) string { return common.BytesToHash([]byte{i}).Hex() }
+	addr := func(i byte) string { return common.BytesToAddress([]byte{i}).String() }
+	chain := &txStates{
+		receipts: map[common.Hash]*types.Receipt{
+			// the cancellation of the second entry
+			common.HexToHash(hash(21)): {TxHash: common.HexToHash(hash(21)), Status: types.ReceiptStatusSuccessful, GasUsed: 21000},
+			// the replacement of the fourth entry
+			common.HexToHash(hash(41)): {TxHash: common.HexToHash(hash(41)), Status: types.ReceiptStatusSuccessful, GasUsed: 60000},
+		},
+		pending: map[common.Hash]bool{common.HexToHash(hash(30)): true},
+	}
+	entries := []*ledger.Entry{
+		{Address: addr(1), TxHash: hash(10)},
+		{Address: addr(2), TxHash: hash(20), CancelTx: hash(21)},
+		{Address: addr(3), TxHash: hash(30)},
+		{Address: addr(4), TxHash: hash(40)},
+	}
+	entries[3].Replace(hash(41))
+	var attempts []*ledger.Attempt
+	for _, e := range entries {
+		e.CumulativePayout = big.NewInt(100)
+		a := &ledger.Attempt{Address: e.Address, Amount: e.CumulativePayout, TxHash: e.TxHash, Status: ledger.StatusPending}
+		if err := l.Record(a); err != nil {
+			t.Fatal(err)
+		}
+		e.AttemptID = a.ID
+		if err := l.Journal(e); err != nil {
+			t.Fatal(err)
+		}
+		attempts = append(attempts, a)
+	}
+
+	New(chain, nil, l, Config{}).reconcile(context.Background())
+
+	tests := []struct {
+		status ledger.Status
+		err    error
+		txHash string
+	}{
+		{ledger.StatusFailed, errDropped, hash(10)},
+		{ledger.StatusFailed, errCancelled, hash(21)},
+		{ledger.StatusPending, nil, hash(30)},
+		{ledger.StatusSuccess, nil, hash(41)},
+	}
+	for i, tt := range tests {
+		a, err := l.Attempt(attempts[i].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		errMsg := ""
+		if tt.err != nil {
+			errMsg = tt.err.Error()
+		}
+		if a.Status != tt.status || a.Error != errMsg || a.TxHash != tt.txHash {
+			t.Errorf("attempt %d is %s %q %s, want %s %q %s", i, a.Status, a.Error, a.TxHash, tt.status, errMsg, tt.txHash)
+		}
+	}
+
+	pending, err := l.Pending("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].TxHash != hash(30) {
+		t.Errorf("journal keeps %d entries, want only the pending one", len(pending))
+	}
+	if len(chain.resynced) != 1 || chain.resynced[0] != common.HexToAddress(addr(1)) {
+		t.Errorf("resynced the nonces of %v, want only the dropped one", chain.resynced)
+	}
+}
@@ -0,0 +1,34 @@
+package cashout
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// report fills in what cashing out the cheque of r would claim and cost,
+// instead of cashing it out. Whatever cannot be read from the chain is left
+// out and noted in the error.
+func (s *Service) report(ctx context.Context, r *Result, sig []byte) {
+	r.Status = StatusBelowThreshold
+	if r.Cheque.Cmp(new(big.Int).Add(r.PaidOut, s.conf.MinPayOut)) > 0 {
+		r.Status = StatusWouldCashOut
+	}
+
+	var errs []string
+	var err error
+	if r.Claimable.Sign() > 0 {
+		s.rpcLimit.do(func() { r.GasCost, err = s.chain.EstimateCashoutCost(ctx, r.Address, r.Cheque, sig) })
+		if err != nil {
+			errs = append(errs, "gas cost: "+err.Error())
+		}
+	}
+	s.rpcLimit.do(func() { r.Balance, err = s.chain.NativeBalance(ctx, r.Address) })
+	if err != nil {
+		errs = append(errs, "balance: "+err.Error())
+	}
+	if len(errs) > 0 {
+		r.Err = errors.New(strings.Join(errs, "; "))
+	}
+}
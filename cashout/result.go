@@ -0,0 +1,49 @@
+package cashout
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+)
+
+// Status is the outcome of cashing out one key.
+type Status string
+
+const (
+	StatusSuccess        Status = "success"
+	StatusFailed         Status = "failed"
+	StatusSkipped        Status = "skipped"
+	StatusNoCheque       Status = "no_cheque"
+	StatusBelowThreshold Status = "below_threshold"
+	// StatusWouldCashOut is reported by dry runs for keys above the threshold
+	StatusWouldCashOut Status = "would_cash_out"
+)
+
+// ErrPending is the error of keys skipped because a journaled cashout of
+// theirs is still in the mempool.
+var ErrPending = errors.New("cashout is still pending")
+
+// Result is the outcome of one key in a run. Amounts are in smallest units;
+// nil amounts were not determined.
+type Result struct {
+	Address   common.Address
+	Status    Status
+	Cheque    *big.Int
+	PaidOut   *big.Int
+	Claimable *big.Int
+	// Cashed is the amount paid out by the cashout
+	Cashed *big.Int
+	// TxHash is the cashout transaction, zero if none was sent
+	TxHash  common.Hash
+	GasUsed uint64
+	// GasCost and Balance are in wei and only reported by dry runs
+	GasCost *big.Int
+	Balance *big.Int
+	Err     error
+}
+
+func (r *Result) fail(status Status, err error) *Result {
+	r.Status = status
+	r.Err = err
+	return r
+}
@@ -0,0 +1,318 @@
+// Package cashout cashes out GPS cheques for a set of keys. For every key it
+// fetches the latest cheque, checks it against the contract and sends
+// cashCheque, recording each attempt in a ledger and journaling sent
+// transactions so an interrupted run never cashes a cheque twice.
+package cashout
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zhaozilong88/cashout/cheque"
+	"github.com/zhaozilong88/cashout/eth"
+	"github.com/zhaozilong88/cashout/ledger"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// Chain is the GPSToken contract and the chain it is deployed on. It is
+// implemented by *eth.Contract.
+type Chain interface {
+	GetPaidOut(ctx context.Context, addr string) (*big.Int, error)
+	VerifyCheque(beneficiary common.Address, cumulativePayout *big.Int, issuerSig []byte) error
+	SimulateCashout(ctx context.Context, beneficiary common.Address, cumulativePayout *big.Int, issuerSig []byte) error
+	EstimateCashoutCost(ctx context.Context, beneficiary common.Address, cumulativePayout *big.Int, issuerSig []byte) (*big.Int, error)
+	Cashout(ctx context.Context, signer eth.Signer, cumulativePayout *big.Int, issuerSig []byte, beforeSend eth.BeforeSend) (*types.Transaction, error)
+	WaitCashout(ctx context.Context, txs ...*types.Transaction) (*eth.CashoutResult, error)
+
+	BalanceOf(ctx context.Context, addr common.Address) (*big.Int, error)
+	NativeBalance(ctx context.Context, addr common.Address) (*big.Int, error)
+	Transfer(ctx context.Context, signer eth.Signer, to common.Address, amount *big.Int) (*types.Transaction, error)
+	Wait(ctx context.Context, txs ...*types.Transaction) (*types.Receipt, error)
+
+	TxState(ctx context.Context, hash common.Hash) (eth.TxState, *types.Receipt, error)
+	Nonces(ctx context.Context, addr common.Address) (mined, pending uint64, err error)
+	PendingTransaction(ctx context.Context, hash common.Hash) (*types.Transaction, error)
+	BumpGasPrice(ctx context.Context, price *big.Int, percent int) (*big.Int, error)
+	Replace(ctx context.Context, signer eth.Signer, tx *types.Transaction, gasPrice *big.Int, beforeSend eth.BeforeSend) (*types.Transaction, error)
+	Cancel(ctx context.Context, signer eth.Signer, nonce uint64, gasPrice *big.Int, beforeSend eth.BeforeSend) (*types.Transaction, error)
+	ResyncNonce(addr common.Address)
+}
+
+// Signer signs the transactions of one key.
+type Signer = eth.Signer
+
+// Config tunes a Service.
+type Config struct {
+	// MinPayOut is the least amount in smallest units a cheque must exceed
+	// the paid out amount by to be cashed out.
+	MinPayOut *big.Int
+	// Simulate runs every cashCheque as an eth_call first and skips keys
+	// that would revert.
+	Simulate bool
+	// DryRun reports what would be cashed out without sending anything.
+	DryRun bool
+	// Workers is the number of keys processed in parallel, APIConcurrency
+	// and RPCConcurrency bound the parallel cheque and chain requests.
+	Workers        int
+	APIConcurrency int
+	RPCConcurrency int
+	// BumpAfter, if set, is how long a cashout may stay pending before it is
+	// replaced with a gas price bumped by BumpPercent, at most MaxBumps times.
+	BumpAfter   time.Duration
+	BumpPercent int
+	MaxBumps    int
+	// SweepTo receives the tokens of every key above SweepKeep on Sweep and,
+	// with AutoSweep, right after a successful cashout.
+	SweepTo   common.Address
+	SweepKeep *big.Int
+	AutoSweep bool
+	// Decimals of the token, for the amounts in log messages
+	Decimals int
+	// Log receives progress messages, nil discards them.
+	Log io.Writer
+}
+
+// Service cashes out cheques.
+type Service struct {
+	conf   Config
+	chain  Chain
+	source cheque.Source
+	ledger *ledger.Ledger
+	log    io.Writer
+
+	apiLimit limiter
+	rpcLimit limiter
+
+	// OnResult, if set, is called with every result of Run in key order as
+	// soon as it is known.
+	OnResult func(*Result)
+}
+
+// New returns a Service cashing out the cheques of source on chain. Every
+// attempt is recorded in l; a nil ledger disables the ledger and journal.
+func New(chain Chain, source cheque.Source, l *ledger.Ledger, conf Config) *Service {
+	if conf.MinPayOut == nil {
+		conf.MinPayOut = new(big.Int)
+	}
+	if conf.SweepKeep == nil {
+		conf.SweepKeep = new(big.Int)
+	}
+	if conf.Workers < 1 {
+		conf.Workers = 1
+	}
+	log := conf.Log
+	if log == nil {
+		log = ioutil.Discard
+	}
+	return &Service{
+		conf:     conf,
+		chain:    chain,
+		source:   source,
+		ledger:   l,
+		log:      log,
+		apiLimit: newLimiter(conf.APIConcurrency),
+		rpcLimit: newLimiter(conf.RPCConcurrency),
+	}
+}
+
+func (s *Service) logf(format string, args ...interface{}) {
+	fmt.Fprintf(s.log, format, args...)
+}
+
+// Run reconciles the journal and then cashes out the cheque of every signer,
+// or with DryRun only reports what would be cashed out. The results are in
+// signer order; signers left when ctx is cancelled have none.
+func (s *Service) Run(ctx context.Context, signers []Signer) ([]*Result, error) {
+	if !s.conf.DryRun {
+		s.reconcile(ctx)
+	}
+	results := make([]*Result, len(signers))
+	var list []*Result
+	ForEach(ctx, s.conf.Workers, len(signers), func(i int) {
+		results[i] = s.cashout(ctx, signers[i])
+	}, func(i int) {
+		if results[i] == nil {
+			return
+		}
+		list = append(list, results[i])
+		if s.OnResult != nil {
+			s.OnResult(results[i])
+		}
+	})
+	return list, ctx.Err()
+}
+
+// ForEach calls work for every index below n with up to workers calls in
+// flight, and done for every index in order as soon as work for it and all
+// earlier indexes returned. Once ctx is cancelled the remaining work is
+// skipped, done is still called.
+func ForEach(ctx context.Context, workers, n int, work func(i int), done func(i int)) {
+	finished := make([]chan struct{}, n)
+	for i := range finished {
+		finished[i] = make(chan struct{})
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() == nil {
+					work(i)
+				}
+				close(finished[i])
+			}
+		}()
+	}
+	go func() {
+		for i := 0; i < n; i++ {
+			jobs <- i
+		}
+		close(jobs)
+	}()
+
+	for i := 0; i < n; i++ {
+		<-finished[i]
+		done(i)
+	}
+	wg.Wait()
+}
+
+// limiter bounds the number of concurrent calls to an endpoint.
+type limiter chan struct{}
+
+func newLimiter(n int) limiter {
+	if n < 1 {
+		n = 1
+	}
+	return make(limiter, n)
+}
+
+func (l limiter) do(f func()) {
+	l <- struct{}{}
+	defer func() { <-l }()
+	f()
+}
+
+// record writes attempt to the ledger, if one is configured. Failing to write
+// the ledger is logged but does not stop the cashout.
+func (s *Service) record(attempt *ledger.Attempt, status ledger.Status, err error) {
+	attempt.Status = status
+	attempt.Error = ""
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if s.ledger == nil {
+		return
+	}
+	if err := s.ledger.Record(attempt); err != nil {
+		s.logf("failed to write ledger, %v\n", err)
+	}
+}
+
+// cashout cashes out the cheque of signer.
+func (s *Service) cashout(ctx context.Context, signer Signer) *Result {
+	addr := signer.Address()
+	r := &Result{Address: addr}
+
+	var c *cheque.Cheque
+	var err error
+	s.apiLimit.do(func() { c, err = s.source.Cheque(ctx, addr) })
+	if err == cheque.ErrNotFound && s.conf.DryRun {
+		c, err = &cheque.Cheque{Beneficiary: addr, Amount: new(big.Int)}, nil
+	}
+	if err != nil {
+		if err == cheque.ErrNotFound {
+			r.Status = StatusNoCheque
+			return r
+		}
+		return r.fail(StatusFailed, fmt.Errorf("failed to get cheque, %w", err))
+	}
+	reward := c.Amount
+	r.Cheque = reward
+	var paidOut *big.Int
+	s.rpcLimit.do(func() { paidOut, err = s.chain.GetPaidOut(ctx, addr.String()) })
+	if err != nil {
+		return r.fail(StatusFailed, fmt.Errorf("failed to get paid out amount, %w", err))
+	}
+	r.PaidOut = paidOut
+	r.Claimable = new(big.Int).Sub(reward, paidOut)
+	if s.conf.DryRun {
+		s.report(ctx, r, c.Signature)
+		return r
+	}
+
+	a := big.NewInt(0).Add(paidOut, s.conf.MinPayOut)
+	if reward.Cmp(a) <= 0 {
+		r.Status = StatusBelowThreshold
+		return r
+	}
+	if s.ledger != nil {
+		pending, err := s.ledger.Pending(addr.String())
+		if err != nil {
+			return r.fail(StatusFailed, fmt.Errorf("failed to read journal, %w", err))
+		}
+		if len(pending) > 0 {
+			r.TxHash = common.HexToHash(pending[0].TxHash)
+			return r.fail(StatusSkipped, fmt.Errorf("%w, %s", ErrPending, pending[0].TxHash))
+		}
+	}
+	attempt := &ledger.Attempt{
+		Address: addr.String(),
+		Amount:  reward,
+		PaidOut: paidOut,
+	}
+	if err := s.chain.VerifyCheque(addr, reward, c.Signature); err != nil {
+		s.record(attempt, ledger.StatusSkipped, err)
+		return r.fail(StatusSkipped, err)
+	}
+	if s.conf.Simulate {
+		s.rpcLimit.do(func() { err = s.chain.SimulateCashout(ctx, addr, reward, c.Signature) })
+		if err != nil {
+			s.record(attempt, ledger.StatusSkipped, err)
+			return r.fail(StatusSkipped, fmt.Errorf("simulation failed, %w", err))
+		}
+	}
+	s.record(attempt, ledger.StatusPending, nil)
+	var tx *types.Transaction
+	s.rpcLimit.do(func() { tx, err = s.chain.Cashout(ctx, signer, reward, c.Signature, s.journal(attempt)) })
+	if err != nil {
+		s.record(attempt, ledger.StatusFailed, err)
+		return r.fail(StatusFailed, fmt.Errorf("failed to send cashout, %w", err))
+	}
+	// waiting only polls for the receipt, so it does not hold an RPC slot
+	cashed, err := s.waitCashout(ctx, signer, attempt, tx)
+	r.TxHash = tx.Hash()
+	if cashed != nil {
+		// the mined transaction, not necessarily the last replacement
+		attempt.TxHash = cashed.Receipt.TxHash.Hex()
+		attempt.GasUsed = cashed.Receipt.GasUsed
+		r.TxHash = cashed.Receipt.TxHash
+		r.GasUsed = cashed.Receipt.GasUsed
+		s.resolve(attempt)
+	}
+	if err != nil {
+		if cashed == nil {
+			// the cashout may have been dropped, ask the node for the
+			// nonce instead of queueing behind a gap
+			s.chain.ResyncNonce(addr)
+		}
+		s.record(attempt, ledger.StatusFailed, err)
+		return r.fail(StatusFailed, err)
+	}
+	s.record(attempt, ledger.StatusSuccess, nil)
+	r.Status = StatusSuccess
+	r.Cashed = cashed.Event.TotalPayout
+
+	if s.conf.AutoSweep {
+		s.sweep(ctx, signer)
+	}
+	return r
+}
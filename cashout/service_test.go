@@ -0,0 +1,184 @@
+package cashout_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhaozilong88/cashout/cashout"
+	"github.com/zhaozilong88/cashout/cheque"
+	"github.com/zhaozilong88/cashout/eth"
+	"github.com/zhaozilong88/cashout/eth/simulated"
+	"github.com/zhaozilong88/cashout/ledger"
+	"io/ioutil"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// forgedSource serves cheque for its beneficiary and the cheques of Source
+// for everyone else.
+type forgedSource struct {
+	cheque.Source
+	cheque *cheque.Cheque
+}
+
+func (s *forgedSource) Cheque(ctx context.Context, beneficiary common.Address) (*cheque.Cheque, error) {
+	if beneficiary == s.cheque.Beneficiary {
+		return s.cheque, nil
+	}
+	return s.Source.Cheque(ctx, beneficiary)
+}
+
+// TestRunOnSimulatedChain deploys GPSToken on a simulated chain and runs the
+// cashout flow against it with four keys: one with a cheque to cash out, one
+// below the threshold, one without a cheque and one with a cheque not signed
+// by the issuer. Cheques come from the fake API over HTTP.
+func TestRunOnSimulatedChain(t *testing.T) {
+	ctx := context.Background()
+	issuer, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []*ecdsa.PrivateKey
+	var signers []cashout.Signer
+	var addrs []common.Address
+	for i := 0; i < 4; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+		signers = append(signers, eth.NewKeySigner(key))
+		addrs = append(addrs, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	ether := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+	chain, err := simulated.New(issuer, ether, addrs...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	contract, err := chain.Contract(eth.Config{
+		GasStrategy:    eth.GasAuto,
+		GasPrice:       eth.DefaultGasPrice,
+		Confirmations:  1,
+		ReceiptTimeout: time.Minute,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decimals := int(contract.Decimals())
+	tokens := func(s string) *big.Int {
+		amount, err := cashout.ParseAmount(s, decimals)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return amount
+	}
+
+	dir, err := ioutil.TempDir("", "cashout-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	l, err := ledger.Open(filepath.Join(dir, "cashout.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	api := cheque.NewServer(issuer, big.NewInt(simulated.ChainID))
+	api.PaidOut = func(ctx context.Context, beneficiary common.Address) (*big.Int, error) {
+		return contract.GetPaidOut(ctx, beneficiary.String())
+	}
+	server := httptest.NewServer(api)
+	defer server.Close()
+	api.Issue(addrs[0], tokens("100"))
+	api.Issue(addrs[1], tokens("5"))
+	// a cheque the key signed itself, VerifyCheque must refuse it
+	forged, err := eth.SignCheque(keys[3], big.NewInt(simulated.ChainID), addrs[3], tokens("100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &forgedSource{Source: cheque.NewHTTPSource(server.URL), cheque: &cheque.Cheque{
+		Beneficiary: addrs[3],
+		Amount:      tokens("100"),
+		PaidOut:     new(big.Int),
+		Signature:   forged,
+	}}
+
+	treasury := crypto.PubkeyToAddress(issuer.PublicKey)
+	service := cashout.New(contract, source, l, cashout.Config{
+		MinPayOut: tokens("10"),
+		Simulate:  true,
+		Workers:   2,
+		SweepTo:   treasury,
+		SweepKeep: new(big.Int),
+		Decimals:  decimals,
+	})
+	run := func() []*cashout.Result {
+		results, err := service.Run(ctx, signers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+	expect := func(results []*cashout.Result, i int, status cashout.Status, cashed *big.Int) {
+		t.Helper()
+		r := results[i]
+		if r.Status != status {
+			t.Fatalf("key %d is %s, want %s (%v)", i, r.Status, status, r.Err)
+		}
+		if cashed != nil && (r.Cashed == nil || r.Cashed.Cmp(cashed) != 0) {
+			t.Fatalf("key %d cashed %v, want %v", i, r.Cashed, cashed)
+		}
+	}
+	expectPaidOut := func(addr common.Address, want *big.Int) {
+		t.Helper()
+		paidOut, err := contract.GetPaidOut(ctx, addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if paidOut.Cmp(want) != 0 {
+			t.Fatalf("%s was paid out %v, want %v", addr.Hex(), paidOut, want)
+		}
+	}
+	balance := func(addr common.Address) *big.Int {
+		b, err := contract.BalanceOf(ctx, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	results := run()
+	expect(results, 0, cashout.StatusSuccess, tokens("100"))
+	expectPaidOut(addrs[0], tokens("100"))
+	expect(results, 1, cashout.StatusBelowThreshold, nil)
+	expect(results, 2, cashout.StatusNoCheque, nil)
+	expect(results, 3, cashout.StatusSkipped, nil)
+
+	// the paid out cheque is not cashed twice
+	expect(run(), 0, cashout.StatusBelowThreshold, nil)
+
+	// a higher cumulative cheque cashes the difference
+	api.Issue(addrs[0], tokens("150"))
+	expect(run(), 0, cashout.StatusSuccess, tokens("50"))
+	expectPaidOut(addrs[0], tokens("150"))
+
+	// the contract credits more than the payout, so the sweep is checked
+	// against the balances before it instead of the cheques
+	held, before := balance(addrs[0]), balance(treasury)
+	if err := service.Sweep(ctx, signers[:1]); err != nil {
+		t.Fatal(err)
+	}
+	if left := balance(addrs[0]); left.Sign() != 0 {
+		t.Fatalf("%v of %v left after sweeping", left, held)
+	}
+	if received := new(big.Int).Sub(balance(treasury), before); received.Cmp(held) != 0 {
+		t.Fatalf("treasury received %v, want %v", received, held)
+	}
+}
@@ -0,0 +1,217 @@
+package cashout
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/zhaozilong88/cashout/eth"
+	"github.com/zhaozilong88/cashout/ledger"
+	"math/big"
+)
+
+var ErrNoLedger = errors.New("the ledger is disabled, it is needed to find journaled cashouts")
+
+// StuckKey is a key whose pending nonce is ahead of its mined nonce, it has
+// transactions waiting in the mempool.
+type StuckKey struct {
+	Signer  Signer
+	Mined   uint64
+	Pending uint64
+}
+
+// Stuck returns the signers with transactions in the mempool.
+func (s *Service) Stuck(ctx context.Context, signers []Signer) []StuckKey {
+	var list []StuckKey
+	for _, signer := range signers {
+		addr := signer.Address()
+		var mined, pending uint64
+		var err error
+		s.rpcLimit.do(func() { mined, pending, err = s.chain.Nonces(ctx, addr) })
+		if err != nil {
+			s.logf("failed to get nonces of %s, %v\n", addr.String(), err)
+			continue
+		}
+		if pending > mined {
+			list = append(list, StuckKey{Signer: signer, Mined: mined, Pending: pending})
+		}
+	}
+	return list
+}
+
+// SpeedUp replaces the journaled cashouts of stuck signers with copies at a
+// bumped gas price. Dry runs only log the replacements.
+func (s *Service) SpeedUp(ctx context.Context, signers []Signer) error {
+	if s.ledger == nil {
+		return ErrNoLedger
+	}
+	for _, k := range s.Stuck(ctx, signers) {
+		addr := k.Signer.Address()
+		entries, err := s.ledger.Pending(addr.String())
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			s.logf("%s has no journaled cashout to speed up, use cancel\n", addr.String())
+			continue
+		}
+		for _, e := range entries {
+			if e.CancelTx != "" {
+				continue
+			}
+			tx, err := s.chain.PendingTransaction(ctx, common.HexToHash(e.TxHash))
+			if err != nil {
+				s.logf("%s cashout %s is not pending, %v\n", addr.String(), e.TxHash, err)
+				continue
+			}
+			if s.conf.DryRun {
+				price, err := s.chain.BumpGasPrice(ctx, tx.GasPrice(), s.conf.BumpPercent)
+				if err != nil {
+					s.logf("%s would not speed up %s, %v\n", addr.String(), e.TxHash, err)
+					continue
+				}
+				s.logf("%s would speed up %s at %s wei\n", addr.String(), e.TxHash, price)
+				continue
+			}
+			replacement, err := s.speedUpTx(ctx, k.Signer, e, tx)
+			if err != nil {
+				s.logf("%s failed to speed up %s, %v\n", addr.String(), e.TxHash, err)
+				continue
+			}
+			s.logf("%s sped up %s with %s at %s wei\n", addr.String(), tx.Hash().Hex(), replacement.Hash().Hex(), replacement.GasPrice())
+		}
+	}
+	return nil
+}
+
+// speedUpTx replaces tx, the current transaction of journal entry e, with a
+// higher gas price. The journal is updated before the replacement is sent.
+func (s *Service) speedUpTx(ctx context.Context, signer Signer, e *ledger.Entry, tx *types.Transaction) (*types.Transaction, error) {
+	price, err := s.chain.BumpGasPrice(ctx, tx.GasPrice(), s.conf.BumpPercent)
+	if err != nil {
+		return nil, err
+	}
+	return s.chain.Replace(ctx, signer, tx, price, func(replacement *types.Transaction) error {
+		e.Replace(replacement.Hash().Hex())
+		s.updateAttempt(e.AttemptID, e.TxHash)
+		return s.ledger.Journal(e)
+	})
+}
+
+// Cancel sends a zero value self transfer at every pending nonce of the
+// stuck signers. Dry runs only log them.
+func (s *Service) Cancel(ctx context.Context, signers []Signer) error {
+	if s.ledger == nil {
+		return ErrNoLedger
+	}
+	for _, k := range s.Stuck(ctx, signers) {
+		addr := k.Signer.Address()
+		entries, err := s.ledger.Pending(addr.String())
+		if err != nil {
+			return err
+		}
+		for nonce := k.Mined; nonce < k.Pending; nonce++ {
+			// the replaced transaction's price is only known for our own
+			// journaled cashouts, anything else is cancelled at the current
+			// gas price plus the bump
+			entry, price := s.pendingAt(ctx, entries, nonce)
+			price, err := s.chain.BumpGasPrice(ctx, price, s.conf.BumpPercent)
+			if err != nil {
+				s.logf("%s failed to cancel nonce %d, %v\n", addr.String(), nonce, err)
+				continue
+			}
+			if s.conf.DryRun {
+				s.logf("%s would cancel nonce %d at %s wei\n", addr.String(), nonce, price)
+				continue
+			}
+			tx, err := s.chain.Cancel(ctx, k.Signer, nonce, price, func(tx *types.Transaction) error {
+				if entry == nil {
+					return nil
+				}
+				entry.CancelTx = tx.Hash().Hex()
+				return s.ledger.Journal(entry)
+			})
+			if err != nil {
+				s.logf("%s failed to cancel nonce %d, %v\n", addr.String(), nonce, err)
+				continue
+			}
+			s.logf("%s cancelled nonce %d with %s\n", addr.String(), nonce, tx.Hash().Hex())
+		}
+		s.chain.ResyncNonce(addr)
+	}
+	return nil
+}
+
+// pendingAt returns the journal entry whose current transaction uses nonce
+// and that transaction's gas price, if there is one in the mempool.
+func (s *Service) pendingAt(ctx context.Context, entries []*ledger.Entry, nonce uint64) (*ledger.Entry, *big.Int) {
+	for _, e := range entries {
+		tx, err := s.chain.PendingTransaction(ctx, common.HexToHash(e.TxHash))
+		if err == nil && tx.Nonce() == nonce {
+			return e, tx.GasPrice()
+		}
+	}
+	return nil, new(big.Int)
+}
+
+// updateAttempt points the ledger attempt with id at a replacement
+// transaction.
+func (s *Service) updateAttempt(id uint64, txHash string) {
+	attempt, err := s.ledger.Attempt(id)
+	if err != nil || attempt == nil {
+		return
+	}
+	attempt.TxHash = txHash
+	s.record(attempt, attempt.Status, nil)
+}
+
+// waitCashout waits for tx like eth.Contract.WaitCashout. With a bump policy
+// a cashout still pending after BumpAfter is replaced with a higher gas
+// price, up to MaxBumps times, and whichever version gets mined counts.
+func (s *Service) waitCashout(ctx context.Context, signer Signer, attempt *ledger.Attempt, tx *types.Transaction) (*eth.CashoutResult, error) {
+	txs := []*types.Transaction{tx}
+	for {
+		bump := s.conf.BumpAfter > 0 && len(txs) <= s.conf.MaxBumps
+		if !bump {
+			return s.chain.WaitCashout(ctx, txs...)
+		}
+		waitCtx, cancel := context.WithTimeout(ctx, s.conf.BumpAfter)
+		result, err := s.chain.WaitCashout(waitCtx, txs...)
+		timedOut := waitCtx.Err() != nil
+		cancel()
+		if err == nil || !timedOut || ctx.Err() != nil {
+			return result, err
+		}
+
+		last := txs[len(txs)-1]
+		price, err := s.chain.BumpGasPrice(ctx, last.GasPrice(), s.conf.BumpPercent)
+		if err != nil {
+			s.logf("%s not speeding up %s, %v\n", attempt.Address, last.Hash().Hex(), err)
+			return s.chain.WaitCashout(ctx, txs...)
+		}
+		replacement, err := s.chain.Replace(ctx, signer, last, price, func(replacement *types.Transaction) error {
+			attempt.TxHash = replacement.Hash().Hex()
+			s.record(attempt, ledger.StatusPending, nil)
+			if s.ledger == nil {
+				return nil
+			}
+			entries, err := s.ledger.Pending(attempt.Address)
+			if err != nil {
+				return err
+			}
+			for _, e := range entries {
+				if e.CumulativePayout.Cmp(attempt.Amount) == 0 {
+					e.Replace(attempt.TxHash)
+					return s.ledger.Journal(e)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			s.logf("%s failed to speed up %s, %v\n", attempt.Address, last.Hash().Hex(), err)
+			return s.chain.WaitCashout(ctx, txs...)
+		}
+		s.logf("%s sped up %s with %s\n", attempt.Address, last.Hash().Hex(), replacement.Hash().Hex())
+		txs = append(txs, replacement)
+	}
+}
@@ -0,0 +1,68 @@
+package cashout
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// Sweep sends the token balance of every signer above SweepKeep to SweepTo.
+// Dry runs only log the transfers. Failures are logged per signer and counted
+// in the returned error.
+func (s *Service) Sweep(ctx context.Context, signers []Signer) error {
+	failed := make([]bool, len(signers))
+	ForEach(ctx, s.conf.Workers, len(signers), func(i int) {
+		failed[i] = !s.sweep(ctx, signers[i])
+	}, func(int) {})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	n := 0
+	for _, f := range failed {
+		if f {
+			n++
+		}
+	}
+	if n > 0 {
+		return fmt.Errorf("%d sweeps failed", n)
+	}
+	return nil
+}
+
+// sweep sweeps the balance of signer, logging what it does. It reports
+// whether the signer needed no sweep or was swept.
+func (s *Service) sweep(ctx context.Context, signer Signer) bool {
+	addr := signer.Address()
+	if addr == s.conf.SweepTo {
+		return true
+	}
+	var balance *big.Int
+	var err error
+	s.rpcLimit.do(func() { balance, err = s.chain.BalanceOf(ctx, addr) })
+	if err != nil {
+		s.logf("failed to get balance of %s, %v\n", addr.String(), err)
+		return false
+	}
+	amount := new(big.Int).Sub(balance, s.conf.SweepKeep)
+	if amount.Sign() <= 0 {
+		return true
+	}
+	if s.conf.DryRun {
+		s.logf("%s would sweep %s to %s\n", addr.String(), FormatAmount(amount, s.conf.Decimals), s.conf.SweepTo.String())
+		return true
+	}
+
+	var tx *types.Transaction
+	s.rpcLimit.do(func() { tx, err = s.chain.Transfer(ctx, signer, s.conf.SweepTo, amount) })
+	if err != nil {
+		s.logf("%s sweep failed, %v\n", addr.String(), err)
+		return false
+	}
+	if _, err := s.chain.Wait(ctx, tx); err != nil {
+		s.logf("%s sweep %s failed, %v\n", addr.String(), tx.Hash().Hex(), err)
+		return false
+	}
+	s.logf("%s swept %s to %s in %s\n", addr.String(), FormatAmount(amount, s.conf.Decimals), s.conf.SweepTo.String(), tx.Hash().Hex())
+	return true
+}
@@ -0,0 +1,74 @@
+package cheque
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"strings"
+)
+
+// ErrNotFound is returned when a source has no cheque for an address.
+var ErrNotFound = errors.New("cheque not found")
+
+// Cheque is the latest cumulative cheque issued to a beneficiary.
+type Cheque struct {
+	Beneficiary common.Address
+	Amount      *big.Int
+	PaidOut     *big.Int
+	Signature   []byte
+}
+
+// Source fetches the latest cheque for a beneficiary.
+type Source interface {
+	Cheque(ctx context.Context, beneficiary common.Address) (*Cheque, error)
+}
+
+// data is the cheque object used by the gpfs API and cheque files.
+type data struct {
+	Amount    bigInt `json:"amount"`
+	PaidOut   bigInt `json:"paid_out"`
+	Signature string `json:"signature"`
+}
+
+func (d data) cheque(beneficiary common.Address) (*Cheque, error) {
+	sig, err := decodeSignature(d.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return &Cheque{
+		Beneficiary: beneficiary,
+		Amount:      new(big.Int).Set(&d.Amount.Int),
+		PaidOut:     new(big.Int).Set(&d.PaidOut.Int),
+		Signature:   sig,
+	}, nil
+}
+
+// bigInt is an integer of any size, given as a JSON number or a decimal
+// string, so amounts beyond 64 bits are not rounded.
+type bigInt struct {
+	big.Int
+}
+
+func (b *bigInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "null" {
+		return nil
+	}
+	if _, ok := b.SetString(s, 10); !ok {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+	return nil
+}
+
+func decodeSignature(s string) ([]byte, error) {
+	if s == "" {
+		return nil, errors.New("cheque has no signature")
+	}
+	sig := common.FromHex(s)
+	if len(sig) != 65 {
+		return nil, errors.New("invalid cheque signature length")
+	}
+	return sig, nil
+}
@@ -0,0 +1,87 @@
+package cheque
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ethereum/go-ethereum/common"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBigInt(t *testing.T) {
+	tests := []struct {
+		json string
+		want string
+		ok   bool
+	}{
+		{`2365437062`, "2365437062", true},
+		{`"2365437062"`, "2365437062", true},
+		// beyond 64 bits, a float64 would round it
+		{`123456789012345678901234567890`, "123456789012345678901234567890", true},
+		{`"123456789012345678901234567890"`, "123456789012345678901234567890", true},
+		{`null`, "0", true},
+		{`"12.5"`, "", false},
+		{`"abc"`, "", false},
+	}
+	for _, tt := range tests {
+		var b bigInt
+		err := json.Unmarshal([]byte(tt.json), &b)
+		if (err == nil) != tt.ok {
+			t.Errorf("unmarshal %s: error %v, want ok %v", tt.json, err, tt.ok)
+			continue
+		}
+		if tt.ok && b.String() != tt.want {
+			t.Errorf("unmarshal %s = %s, want %s", tt.json, b.String(), tt.want)
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cashout-cheques")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sig := strings.Repeat("ab", 65)
+	filename := filepath.Join(dir, "cheques.json")
+	content := `{
+		"0x664E01FC0F9A5DC2E814AF517DCE25071525544F": {"amount": 2365437062, "paid_out": "2066895147", "signature": "` + sig + `"},
+		"0x0000000000000000000000000000000000000002": {"amount": 1, "paid_out": null, "signature": "0x01"}
+	}`
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileSource(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// the file spells the address in upper case, lookups ignore the case
+	beneficiary := common.HexToAddress("0x664e01fc0f9a5dc2e814af517dce25071525544f")
+	c, err := s.Cheque(ctx, beneficiary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Beneficiary != beneficiary || c.Amount.String() != "2365437062" || c.PaidOut.String() != "2066895147" || len(c.Signature) != 65 {
+		t.Errorf("cheque %s %v %v %x", c.Beneficiary.Hex(), c.Amount, c.PaidOut, c.Signature)
+	}
+
+	if _, err := s.Cheque(ctx, common.HexToAddress("0x0000000000000000000000000000000000000002")); err == nil {
+		t.Error("accepted a cheque with a short signature")
+	}
+	if _, err := s.Cheque(ctx, common.HexToAddress("0x0000000000000000000000000000000000000003")); err != ErrNotFound {
+		t.Errorf("cheque of an unknown address: %v, want ErrNotFound", err)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte(`{"0x01": {"amount": "1.5"}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileSource(filename); err == nil {
+		t.Error("accepted a fractional amount")
+	}
+}
@@ -0,0 +1,71 @@
+package cheque
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the base URL of the public gpfs cheque API.
+const DefaultURL = "https://api.gpfs.xyz"
+
+// HTTPSource fetches cheques from a gpfs compatible API:
+//
+//	GET {base}/v1/cheque?address=0x664e01fc0f9a5dc2e814af517dce25071525544f
+//	{"code":0,"msg":"success","data":{"amount":2365437062,"paid_out":2066895147,"signature":"2b6f..."}}
+type HTTPSource struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewHTTPSource(baseURL string) *HTTPSource {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	return &HTTPSource{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *HTTPSource) Cheque(ctx context.Context, beneficiary common.Address) (*Cheque, error) {
+	u := s.baseURL + "/v1/cheque?address=" + url.QueryEscape(strings.ToLower(beneficiary.Hex()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cheque api: %s", res.Status)
+	}
+
+	ret := struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data *data  `json:"data"`
+	}{}
+	if err := json.Unmarshal(body, &ret); err != nil {
+		return nil, fmt.Errorf("cheque api: %v", err)
+	}
+	if ret.Code != 0 {
+		return nil, fmt.Errorf("cheque api: code %d, %s", ret.Code, ret.Msg)
+	}
+	if ret.Data == nil || ret.Data.Amount.Sign() == 0 {
+		return nil, ErrNotFound
+	}
+	return ret.Data.cheque(beneficiary)
+}
@@ -0,0 +1,127 @@
+package cheque
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhaozilong88/cashout/eth"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Server is a fake gpfs cheque API for development and integration tests. It
+// serves the same GET /v1/cheque?address= responses HTTPSource reads, signing
+// every cheque on request with an issuer key over the EIP-712 domain of the
+// given chain.
+type Server struct {
+	issuer  *ecdsa.PrivateKey
+	chainId *big.Int
+
+	// PaidOut, if set, fills the paid_out field, usually from the contract.
+	// Without it paid_out is 0, which the cashout flow does not rely on.
+	PaidOut func(ctx context.Context, beneficiary common.Address) (*big.Int, error)
+
+	mu      sync.Mutex
+	amounts map[common.Address]*big.Int
+}
+
+func NewServer(issuer *ecdsa.PrivateKey, chainId *big.Int) *Server {
+	return &Server{
+		issuer:  issuer,
+		chainId: new(big.Int).Set(chainId),
+		amounts: map[common.Address]*big.Int{},
+	}
+}
+
+// Issue sets the cumulative payout of the cheque served for beneficiary.
+func (s *Server) Issue(beneficiary common.Address, cumulativePayout *big.Int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.amounts[beneficiary] = new(big.Int).Set(cumulativePayout)
+}
+
+// LoadFile issues the cheques of a JSON file mapping addresses to cumulative
+// payouts in smallest units:
+//
+//	{"0x664e01fc0f9a5dc2e814af517dce25071525544f": 2365437062}
+func (s *Server) LoadFile(filename string) error {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var m map[string]bigInt
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return err
+	}
+	for addr, amount := range m {
+		if !common.IsHexAddress(addr) {
+			return fmt.Errorf("invalid address %q", addr)
+		}
+		amount := amount
+		s.Issue(common.HexToAddress(addr), &amount.Int)
+	}
+	return nil
+}
+
+type response struct {
+	Code int           `json:"code"`
+	Msg  string        `json:"msg"`
+	Data *responseData `json:"data"`
+}
+
+type responseData struct {
+	Amount    *big.Int `json:"amount"`
+	PaidOut   *big.Int `json:"paid_out"`
+	Signature string   `json:"signature"`
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/cheque" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	ret, err := s.response(r.Context(), r.URL.Query().Get("address"))
+	if err != nil {
+		ret = &response{Code: 1, Msg: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ret)
+}
+
+func (s *Server) response(ctx context.Context, address string) (*response, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid address %q", address)
+	}
+	beneficiary := common.HexToAddress(address)
+	s.mu.Lock()
+	amount, ok := s.amounts[beneficiary]
+	s.mu.Unlock()
+	if !ok {
+		return &response{Msg: "success"}, nil
+	}
+	sig, err := eth.SignCheque(s.issuer, s.chainId, beneficiary, amount)
+	if err != nil {
+		return nil, err
+	}
+	paidOut := new(big.Int)
+	if s.PaidOut != nil {
+		if paidOut, err = s.PaidOut(ctx, beneficiary); err != nil {
+			return nil, fmt.Errorf("failed to get paid out amount of %s, %v", strings.ToLower(beneficiary.Hex()), err)
+		}
+	}
+	return &response{Msg: "success", Data: &responseData{
+		Amount:    amount,
+		PaidOut:   paidOut,
+		Signature: hex.EncodeToString(sig),
+	}}, nil
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhaozilong88/cashout/cashout"
+	"github.com/zhaozilong88/cashout/cheque"
+	"github.com/zhaozilong88/cashout/eth"
+	"github.com/zhaozilong88/cashout/ledger"
+	"io"
+	"math/rand"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// command is a subcommand of the CLI. All commands share the global flags;
+// keys, contract and ledger tell which parts of the app a command needs set
+// up. The ledger is locked while open, so only commands writing it open it.
+type command struct {
+	name     string
+	summary  string
+	keys     bool
+	contract bool
+	ledger   bool
+	run      func(ctx context.Context, a *app) error
+}
+
+var commands = []*command{
+	{name: "cashout", summary: "cash out the cheques of all keys (default)", keys: true, contract: true, ledger: true, run: runCashout},
+	{name: "status", summary: "show cheque, paid out and claimable amount per address without sending anything", keys: true, contract: true, run: runStatus},
+	{name: "balance", summary: "show GPS token and BNB balances per address", keys: true, contract: true, run: runBalance},
+	{name: "info", summary: "show GPSToken contract metadata", contract: true, run: runInfo},
+	{name: "keys", summary: "list the addresses of all keys and validate the key file", keys: true, run: runKeys},
+	{name: "stuck", summary: "list addresses with transactions stuck in the mempool", keys: true, contract: true, run: runStuck},
+	{name: "speedup", summary: "rebroadcast journaled cashouts with a higher gas price", keys: true, contract: true, ledger: true, run: runSpeedUp},
+	{name: "cancel", summary: "cancel stuck transactions with a zero value self transfer", keys: true, contract: true, ledger: true, run: runCancel},
+	{name: "sweep", summary: "send the GPS tokens of all keys to -sweep_to", keys: true, contract: true, run: runSweep},
+	{name: "fund", summary: "top up the BNB of all keys from -fund_key_file", keys: true, contract: true, run: runFund},
+	{name: "fake-api", summary: "serve signed cheques like the gpfs API, for tests against a dev chain", run: runFakeAPI},
+}
+
+func lookupCommand(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// app holds what the commands work with, set up as far as the command
+// needs it.
+type app struct {
+	cfg      config
+	keys     []*ecdsa.PrivateKey
+	named    []namedKey
+	signers  []cashout.Signer
+	contract *eth.Contract
+	service  *cashout.Service
+	printer  *printer
+	out      io.Writer
+	ledger   *ledger.Ledger
+}
+
+func newApp(cmd *command, cfg config) (*app, error) {
+	a := &app{cfg: cfg, out: os.Stdout}
+	if cmd.keys {
+		named, err := loadNamedKeys(cfg.Keys, a.messages())
+		if err != nil {
+			return nil, err
+		}
+		if len(named) == 0 {
+			return nil, errors.New("no key in file")
+		}
+		a.named = named
+		// the same address twice would be cashed out by two workers at
+		// once; keys lists the duplicates itself
+		seen := map[common.Address]string{}
+		for _, k := range named {
+			addr := crypto.PubkeyToAddress(k.key.PublicKey)
+			if first, ok := seen[addr]; ok {
+				if cmd.name != "keys" {
+					fmt.Fprintf(a.messages(), "skipping duplicate key %s from %s, already loaded from %s\n", addr.Hex(), k.source, first)
+				}
+				continue
+			}
+			seen[addr] = k.source
+			a.keys = append(a.keys, k.key)
+			a.signers = append(a.signers, eth.NewKeySigner(k.key))
+		}
+	}
+	if !cmd.contract {
+		return a, nil
+	}
+
+	contract, err := eth.NewContract(cfg.Eth)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s, %v", cfg.Eth.Network, err)
+	}
+	a.contract = contract
+	decimals := int(contract.Decimals())
+	if cfg.Decimals >= 0 && cfg.Decimals != decimals {
+		return nil, fmt.Errorf("token has %d decimals, expected %d", decimals, cfg.Decimals)
+	}
+	minPayOut, err := cashout.ParseAmount(cfg.MinPayOut, decimals)
+	if err != nil {
+		return nil, fmt.Errorf("invalid min_pay_out, %v", err)
+	}
+	keep, err := cashout.ParseAmount(cfg.Sweep.Keep, decimals)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sweep_keep, %v", err)
+	}
+	var source cheque.Source = cheque.NewHTTPSource(cfg.Cheque.API)
+	if cfg.Cheque.File != "" {
+		source, err = cheque.NewFileSource(cfg.Cheque.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cheque file, %v", err)
+		}
+	}
+	if cmd.ledger && cfg.Ledger != "" {
+		a.ledger, err = ledger.Open(cfg.Ledger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open ledger, %v", err)
+		}
+	}
+	a.printer = &printer{output: cfg.Output, decimals: decimals, dryRun: cfg.DryRun || cmd.name == "status"}
+	a.service = cashout.New(contract, source, a.ledger, cashout.Config{
+		MinPayOut:      minPayOut,
+		Simulate:       cfg.Simulate,
+		DryRun:         a.printer.dryRun,
+		Workers:        cfg.Workers,
+		APIConcurrency: cfg.APIConcurrency,
+		RPCConcurrency: cfg.RPCConcurrency,
+		BumpAfter:      cfg.Bump.After,
+		BumpPercent:    cfg.Bump.Percent,
+		MaxBumps:       cfg.Bump.MaxBumps,
+		SweepTo:        common.HexToAddress(cfg.Sweep.To),
+		SweepKeep:      keep,
+		AutoSweep:      cfg.Sweep.Auto,
+		Decimals:       decimals,
+		Log:            messageWriter(cfg.Output, a.out),
+	})
+	return a, nil
+}
+
+func (a *app) close() {
+	if a.ledger != nil {
+		a.ledger.Close()
+	}
+}
+
+// messages returns where progress and error messages go.
+func (a *app) messages() io.Writer {
+	return messageWriter(a.cfg.Output, a.out)
+}
+
+// cashout runs the service once, writing a record per key and a summary.
+// Dry runs in text output print a table. It fails if the run was interrupted
+// or any key failed.
+func (a *app) cashout(ctx context.Context) error {
+	records := a.out
+	var tw *tabwriter.Writer
+	if a.printer.dryRun && a.printer.output == outputText {
+		tw = tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, reportHeader)
+		records = tw
+	}
+	a.printer.writeHeader(records)
+	s := newSummary()
+	failed := 0
+	a.service.OnResult = func(r *cashout.Result) {
+		s.add(r)
+		if r.Status == cashout.StatusFailed {
+			failed++
+		}
+		a.printer.emit(records, r)
+	}
+	_, err := a.service.Run(ctx, a.signers)
+	if tw != nil {
+		tw.Flush()
+	}
+	s.endpoints = a.contract.EndpointStats()
+	a.printer.emitSummary(a.out, s)
+	if a.printer.output == outputText {
+		a.endpointSummary()
+	}
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d cashouts failed", failed)
+	}
+	return nil
+}
+
+func (a *app) endpointSummary() {
+	if stats := a.contract.EndpointStats(); len(stats) > 1 {
+		for _, s := range stats {
+			fmt.Fprintf(a.messages(), "rpc %s healthy %v calls %d errors %d\n", s.URL, s.Healthy, s.Calls, s.Errors)
+		}
+	}
+}
+
+func runCashout(ctx context.Context, a *app) error {
+	if !a.cfg.Daemon.Enabled {
+		return a.cashout(ctx)
+	}
+	next, err := newSchedule(a.cfg.Daemon.Cron, a.cfg.Daemon.Interval)
+	if err != nil {
+		return fmt.Errorf("invalid schedule, %v", err)
+	}
+	rand.Seed(time.Now().UnixNano())
+	runDaemon(ctx, a.cashout, next, a.cfg.Daemon.Jitter, a.messages())
+	return nil
+}
+
+// runStatus reports what a cashout would do, it is a cashout dry run.
+func runStatus(ctx context.Context, a *app) error {
+	return a.cashout(ctx)
+}
+
+func runStuck(ctx context.Context, a *app) error {
+	for _, s := range a.service.Stuck(ctx, a.signers) {
+		fmt.Fprintf(a.out, "%s mined nonce %d pending nonce %d\n", s.Signer.Address().String(), s.Mined, s.Pending)
+	}
+	return nil
+}
+
+func runSpeedUp(ctx context.Context, a *app) error {
+	return a.service.SpeedUp(ctx, a.signers)
+}
+
+func runCancel(ctx context.Context, a *app) error {
+	return a.service.Cancel(ctx, a.signers)
+}
+
+func runSweep(ctx context.Context, a *app) error {
+	if !common.IsHexAddress(a.cfg.Sweep.To) {
+		return errors.New("sweep needs -sweep_to")
+	}
+	err := a.service.Sweep(ctx, a.signers)
+	a.endpointSummary()
+	return err
+}
+
+func runFund(ctx context.Context, a *app) error {
+	p, err := newFundPolicy(a.cfg.Fund, a.cfg.DryRun, a.messages())
+	if err != nil {
+		return err
+	}
+	return fundKeys(ctx, a.contract, p, a.keys, a.out)
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/robfig/cron/v3"
+	"github.com/zhaozilong88/cashout/cashout"
+	"github.com/zhaozilong88/cashout/eth"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// envPrefix prefixes the environment variable of every flag, e.g.
+// CASHOUT_GAS_PRICE for -gas_price.
+const envPrefix = "CASHOUT_"
+
+type config struct {
+	Profile        string             `yaml:"profile"`
+	Profiles       map[string]profile `yaml:"profiles"`
+	Decimals       int                `yaml:"decimals"`
+	Eth            eth.Config         `yaml:"eth"`
+	Keys           keysConfig         `yaml:"keys"`
+	Cheque         chequeConfig       `yaml:"cheque"`
+	MinPayOut      string             `yaml:"min_pay_out"`
+	Simulate       bool               `yaml:"simulate"`
+	Workers        int                `yaml:"workers"`
+	APIConcurrency int                `yaml:"api_concurrency"`
+	RPCConcurrency int                `yaml:"rpc_concurrency"`
+	Ledger         string             `yaml:"ledger"`
+	Bump           bumpConfig         `yaml:"bump"`
+	Sweep          sweepConfig        `yaml:"sweep"`
+	Fund           fundConfig         `yaml:"fund"`
+	FakeAPI        fakeAPIConfig      `yaml:"fake_api"`
+	DryRun         bool               `yaml:"dry_run"`
+	Output         string             `yaml:"output"`
+	Daemon         daemonConfig       `yaml:"daemon"`
+}
+
+type keysConfig struct {
+	KeyFile      string `yaml:"key_file"`
+	Keystore     string `yaml:"keystore"`
+	PasswordFile string `yaml:"password_file"`
+	MnemonicFile string `yaml:"mnemonic_file"`
+	HDPath       string `yaml:"hd_path"`
+	HDStart      int    `yaml:"hd_start"`
+	HDCount      int    `yaml:"hd_count"`
+}
+
+type chequeConfig struct {
+	API  string `yaml:"api"`
+	File string `yaml:"file"`
+}
+
+type bumpConfig struct {
+	After    time.Duration `yaml:"after"`
+	Percent  int           `yaml:"percent"`
+	MaxBumps int           `yaml:"max_bumps"`
+}
+
+type sweepConfig struct {
+	To   string `yaml:"to"`
+	Keep string `yaml:"keep"`
+	Auto bool   `yaml:"auto"`
+}
+
+type fundConfig struct {
+	KeyFile       string `yaml:"key_file"`
+	Cashouts      int64  `yaml:"cashouts"`
+	MaxPerAddress string `yaml:"max_per_address"`
+	MaxTotal      string `yaml:"max_total"`
+}
+
+type fakeAPIConfig struct {
+	Listen        string `yaml:"listen"`
+	IssuerKeyFile string `yaml:"issuer_key_file"`
+	Cheques       string `yaml:"cheques"`
+}
+
+type daemonConfig struct {
+	Enabled  bool          `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+	Cron     string        `yaml:"cron"`
+	Jitter   time.Duration `yaml:"jitter"`
+}
+
+func defaultConfig() config {
+	c := config{
+		Profile: defaultProfile,
+		Eth: eth.Config{
+			Confirmations:  1,
+			ReceiptTimeout: 5 * time.Minute,
+			GasStrategy:    eth.GasFixed,
+			GasMultiplier:  1.2,
+			MaxGasPrice:    20,
+		},
+		Keys: keysConfig{
+			KeyFile: "key.txt",
+			HDPath:  "m/44'/60'/0'/0/{i}",
+			HDCount: 1,
+		},
+		Decimals:       -1,
+		MinPayOut:      "10000",
+		Simulate:       true,
+		Workers:        8,
+		APIConcurrency: 4,
+		RPCConcurrency: 4,
+		Ledger:         "cashout.db",
+		Output:         outputText,
+		Bump: bumpConfig{
+			Percent:  20,
+			MaxBumps: 3,
+		},
+		Sweep: sweepConfig{
+			Keep: "0",
+		},
+		Fund: fundConfig{
+			Cashouts:      3,
+			MaxPerAddress: "0.01",
+			MaxTotal:      "0.1",
+		},
+		FakeAPI: fakeAPIConfig{
+			Listen: "127.0.0.1:8080",
+		},
+		Daemon: daemonConfig{
+			Interval: time.Hour,
+		},
+	}
+	profiles[defaultProfile].apply(&c)
+	return c
+}
+
+// bindFlags registers a flag for every setting of c.
+func bindFlags(fs *flag.FlagSet, c *config) {
+	fs.StringVar(&c.Profile, "profile", c.Profile, "network profile: bsc-mainnet, bsc-testnet, local-dev or one from the config file")
+	fs.Uint64Var(&c.Eth.ChainID, "chain_id", c.Eth.ChainID, "expected chain ID, 0 to accept any")
+	fs.IntVar(&c.Decimals, "decimals", c.Decimals, "expected token decimals, -1 to accept what the contract reports")
+	fs.StringVar(&c.Eth.Network, "network", c.Eth.Network, "RPC endpoint")
+	fs.Var((*stringList)(&c.Eth.Endpoints), "endpoints", "comma separated RPC endpoints to fail over to after -network")
+	fs.DurationVar(&c.Eth.MaxBlockAge, "max_block_age", c.Eth.MaxBlockAge, "endpoints whose latest block is older count as unhealthy")
+	fs.StringVar(&c.Eth.ContractAddress, "contract", c.Eth.ContractAddress, "GPSToken contract address")
+	fs.Int64Var(&c.Eth.GasPrice, "gas_price", c.Eth.GasPrice, "gas price Gwei, 0 for 5 or the oracle price with -gas_strategy auto")
+	fs.Uint64Var(&c.Eth.GasLimit, "gas_limit", c.Eth.GasLimit, "gas limit, 0 for 100000 or an estimate with -gas_strategy auto")
+	fs.StringVar(&c.Eth.GasStrategy, "gas_strategy", c.Eth.GasStrategy, "fixed: use -gas_limit/-gas_price, auto: estimate them unless given explicitly")
+	fs.Float64Var(&c.Eth.GasMultiplier, "gas_multiplier", c.Eth.GasMultiplier, "safety multiplier applied to estimated gas limits")
+	fs.IntVar(&c.Eth.GasPricePercentile, "gas_price_percentile", c.Eth.GasPricePercentile, "with auto gas, use this percentile of recent blocks' gas prices instead of eth_gasPrice")
+	fs.IntVar(&c.Eth.GasPriceBlocks, "gas_price_blocks", c.Eth.GasPriceBlocks, "recent blocks sampled by -gas_price_percentile, 0 for 20")
+	fs.Int64Var(&c.Eth.MaxGasPrice, "max_gas_price", c.Eth.MaxGasPrice, "upper cap for automatic gas prices in Gwei, 0 for none")
+	fs.Uint64Var(&c.Eth.Confirmations, "confirmations", c.Eth.Confirmations, "blocks to wait for before a cashout counts as done")
+	fs.DurationVar(&c.Eth.ReceiptTimeout, "receipt_timeout", c.Eth.ReceiptTimeout, "max time to wait for a cashout receipt")
+
+	fs.StringVar(&c.Keys.KeyFile, "key_file", c.Keys.KeyFile, "key file")
+	fs.StringVar(&c.Keys.Keystore, "keystore", c.Keys.Keystore, "directory of encrypted JSON keystore files")
+	fs.StringVar(&c.Keys.PasswordFile, "password_file", c.Keys.PasswordFile, "file containing the keystore passphrase")
+	fs.StringVar(&c.Keys.MnemonicFile, "mnemonic_file", c.Keys.MnemonicFile, "file containing a BIP-39 mnemonic (default $"+mnemonicEnv+")")
+	fs.StringVar(&c.Keys.HDPath, "hd_path", c.Keys.HDPath, "derivation path template, {i} is replaced by the index")
+	fs.IntVar(&c.Keys.HDStart, "hd_start", c.Keys.HDStart, "first derivation index")
+	fs.IntVar(&c.Keys.HDCount, "hd_count", c.Keys.HDCount, "number of addresses to derive")
+
+	fs.StringVar(&c.Cheque.API, "cheque_api", c.Cheque.API, "cheque API base URL")
+	fs.StringVar(&c.Cheque.File, "cheque_file", c.Cheque.File, "read cheques from a JSON file instead of the API")
+
+	fs.StringVar(&c.MinPayOut, "min_pay_out", c.MinPayOut, "min pay out in tokens, e.g. 10000 or 0.5")
+	fs.BoolVar(&c.Simulate, "simulate", c.Simulate, "simulate cashCheque with eth_call and skip keys that would revert")
+	fs.IntVar(&c.Workers, "workers", c.Workers, "number of keys processed in parallel")
+	fs.IntVar(&c.APIConcurrency, "api_concurrency", c.APIConcurrency, "max parallel cheque API requests")
+	fs.IntVar(&c.RPCConcurrency, "rpc_concurrency", c.RPCConcurrency, "max parallel RPC requests")
+	fs.StringVar(&c.Ledger, "ledger", c.Ledger, "database recording every cashout attempt, empty to disable")
+
+	fs.DurationVar(&c.Bump.After, "bump_after", c.Bump.After, "speed up cashouts still pending after this long, 0 to never")
+	fs.IntVar(&c.Bump.Percent, "bump_percent", c.Bump.Percent, "gas price increase of speed up and cancel transactions in percent")
+	fs.IntVar(&c.Bump.MaxBumps, "max_bumps", c.Bump.MaxBumps, "max automatic speed ups per cashout")
+
+	fs.StringVar(&c.Sweep.To, "sweep_to", c.Sweep.To, "treasury address that sweep sends tokens to")
+	fs.StringVar(&c.Sweep.Keep, "sweep_keep", c.Sweep.Keep, "tokens to leave on every address when sweeping")
+	fs.BoolVar(&c.Sweep.Auto, "auto_sweep", c.Sweep.Auto, "sweep an address right after a successful cashout")
+
+	fs.StringVar(&c.Fund.KeyFile, "fund_key_file", c.Fund.KeyFile, "key file of the address that fund sends BNB from")
+	fs.Int64Var(&c.Fund.Cashouts, "fund_cashouts", c.Fund.Cashouts, "fund tops up addresses to cover this many cashouts")
+	fs.StringVar(&c.Fund.MaxPerAddress, "fund_max", c.Fund.MaxPerAddress, "max BNB fund sends to one address, 0 for no cap")
+	fs.StringVar(&c.Fund.MaxTotal, "fund_max_total", c.Fund.MaxTotal, "max BNB fund sends in total, 0 for no cap")
+	fs.StringVar(&c.FakeAPI.Listen, "fake_api_listen", c.FakeAPI.Listen, "address fake-api listens on")
+	fs.StringVar(&c.FakeAPI.IssuerKeyFile, "fake_api_issuer_key", c.FakeAPI.IssuerKeyFile, "key file of the issuer fake-api signs cheques with")
+	fs.StringVar(&c.FakeAPI.Cheques, "fake_api_cheques", c.FakeAPI.Cheques, "JSON file mapping addresses to the cumulative payouts fake-api serves")
+	fs.BoolVar(&c.DryRun, "dry_run", c.DryRun, "only print what would be cashed out or sent")
+	fs.StringVar(&c.Output, "output", c.Output, "output format of the commands: text, json (one object per line) or csv")
+
+	fs.BoolVar(&c.Daemon.Enabled, "daemon", c.Daemon.Enabled, "keep running and cash out on a schedule")
+	fs.DurationVar(&c.Daemon.Interval, "interval", c.Daemon.Interval, "time between cycles in daemon mode")
+	fs.StringVar(&c.Daemon.Cron, "cron", c.Daemon.Cron, "cron expression for daemon cycles, overrides -interval")
+	fs.DurationVar(&c.Daemon.Jitter, "jitter", c.Daemon.Jitter, "random delay of up to this long added to every cycle")
+}
+
+// loadConfig layers the settings of c after fs has been parsed: the selected
+// network profile overrides the defaults, the YAML file overrides the
+// profile, environment variables override the file and flags given on the
+// command line override everything. A profile chosen explicitly, by flag,
+// environment or the file's profile key, also overrides the file for the
+// settings it defines, so a test profile never runs with mainnet values left
+// in the file. The RPC, contract and cheque settings are only validated if
+// contract is set, for commands that talk to the chain.
+func loadConfig(fs *flag.FlagSet, c *config, filename string, contract bool) error {
+	explicit := map[string]string{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = f.Value.String() })
+
+	if filename == "" {
+		filename = os.Getenv(envPrefix + "CONFIG")
+	}
+	var data []byte
+	if filename != "" {
+		var err error
+		data, err = ioutil.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	// the profile sits below the file, so it has to be picked before the
+	// file is applied
+	var head struct {
+		Profile  string             `yaml:"profile"`
+		Profiles map[string]profile `yaml:"profiles"`
+		Eth      struct {
+			Network   string   `yaml:"network"`
+			Endpoints []string `yaml:"endpoints"`
+		} `yaml:"eth"`
+	}
+	if err := yaml.Unmarshal(data, &head); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	name, chosen := c.Profile, head.Profile != ""
+	if chosen {
+		name = head.Profile
+	}
+	if v, ok := os.LookupEnv(envPrefix + "PROFILE"); ok {
+		name, chosen = v, true
+	}
+	if v, ok := explicit["profile"]; ok {
+		name, chosen = v, true
+	}
+	p, err := lookupProfile(name, head.Profiles)
+	if err != nil {
+		return err
+	}
+	p.apply(c)
+
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("%s: %v", filename, err)
+	}
+	fileEndpoints := head.Eth.Network != "" || head.Eth.Endpoints != nil
+	if chosen {
+		p.own(c)
+		fileEndpoints = fileEndpoints && len(p.Endpoints) == 0
+	}
+
+	// set records which of network and endpoints were given in any layer
+	set := map[string]bool{
+		"network":   fileEndpoints && head.Eth.Network != "",
+		"endpoints": fileEndpoints && head.Eth.Endpoints != nil,
+	}
+	var errs configErrors
+	fs.VisitAll(func(f *flag.Flag) {
+		if _, ok := explicit[f.Name]; ok || f.Name == "config" {
+			return
+		}
+		name := envPrefix + strings.ToUpper(f.Name)
+		if v, ok := os.LookupEnv(name); ok {
+			set[f.Name] = true
+			if err := f.Value.Set(v); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	})
+	for name, v := range explicit {
+		set[name] = true
+		if err := fs.Set(name, v); err != nil {
+			errs = append(errs, fmt.Sprintf("-%s: %v", name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	// configured endpoints replace the ones of the profile instead of
+	// failing over to nodes the operator never chose
+	if set["network"] && !set["endpoints"] {
+		c.Eth.Endpoints = nil
+	}
+	if set["endpoints"] && !set["network"] {
+		c.Eth.Network = ""
+		if len(c.Eth.Endpoints) > 0 {
+			c.Eth.Network = c.Eth.Endpoints[0]
+		}
+	}
+	return c.validate(contract)
+}
+
+// stringList is a comma separated list flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = nil
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// configErrors collects every invalid setting, so they can be fixed at once.
+type configErrors []string
+
+func (e configErrors) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e, "\n  ")
+}
+
+func (c *config) validate(contract bool) error {
+	var errs configErrors
+	check := func(ok bool, field, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, field+": "+fmt.Sprintf(format, args...))
+		}
+	}
+
+	if contract {
+		check(validRPC(c.Eth.Network), "eth.network", "invalid RPC endpoint %q", c.Eth.Network)
+		for i, endpoint := range c.Eth.Endpoints {
+			check(validRPC(endpoint), fmt.Sprintf("eth.endpoints[%d]", i), "invalid RPC endpoint %q", endpoint)
+		}
+		check(common.IsHexAddress(c.Eth.ContractAddress), "eth.contract_address", "invalid address %q", c.Eth.ContractAddress)
+		if c.Cheque.File == "" {
+			u, err := url.Parse(c.Cheque.API)
+			check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "", "cheque.api", "invalid URL %q", c.Cheque.API)
+		}
+	}
+	check(c.Eth.MaxBlockAge >= 0, "eth.max_block_age", "must not be negative")
+	check(c.Eth.GasStrategy == eth.GasFixed || c.Eth.GasStrategy == eth.GasAuto, "eth.gas_strategy", "must be %s or %s", eth.GasFixed, eth.GasAuto)
+	check(c.Eth.GasPrice >= 0, "eth.gas_price", "must not be negative")
+	check(c.Eth.GasMultiplier >= 1, "eth.gas_multiplier", "must be at least 1")
+	check(c.Eth.GasPricePercentile >= 0 && c.Eth.GasPricePercentile <= 100, "eth.gas_price_percentile", "must be between 0 and 100")
+	check(c.Eth.GasPriceBlocks >= 0, "eth.gas_price_blocks", "must not be negative")
+	check(c.Eth.MaxGasPrice >= 0, "eth.max_gas_price", "must not be negative")
+	check(c.Eth.ReceiptTimeout >= 0, "eth.receipt_timeout", "must not be negative")
+
+	check(c.Keys.HDStart >= 0, "keys.hd_start", "must not be negative")
+	check(c.Keys.HDCount >= 0, "keys.hd_count", "must not be negative")
+	_, err := accounts.ParseDerivationPath(strings.Replace(c.Keys.HDPath, "{i}", "0", -1))
+	check(err == nil && strings.Contains(c.Keys.HDPath, "{i}"), "keys.hd_path", "invalid path template %q", c.Keys.HDPath)
+	if c.Keys.Keystore != "" {
+		fi, err := os.Stat(c.Keys.Keystore)
+		check(err == nil && fi.IsDir(), "keys.keystore", "%q is not a directory", c.Keys.Keystore)
+	}
+
+	check(c.Decimals >= -1 && c.Decimals <= 77, "decimals", "must be between -1 and 77")
+	check(cashout.ValidAmount(c.MinPayOut), "min_pay_out", "invalid amount %q", c.MinPayOut)
+	check(c.Workers >= 1, "workers", "must be at least 1")
+	check(c.APIConcurrency >= 1, "api_concurrency", "must be at least 1")
+	check(c.RPCConcurrency >= 1, "rpc_concurrency", "must be at least 1")
+	check(c.Output == outputText || c.Output == outputJSON || c.Output == outputCSV, "output", "must be %s, %s or %s", outputText, outputJSON, outputCSV)
+
+	check(c.Bump.After >= 0, "bump.after", "must not be negative")
+	check(c.Bump.Percent >= 10, "bump.percent", "must be at least 10, nodes reject smaller increases")
+	check(c.Bump.MaxBumps >= 0, "bump.max_bumps", "must not be negative")
+
+	if c.Sweep.To != "" || c.Sweep.Auto {
+		check(common.IsHexAddress(c.Sweep.To), "sweep.to", "invalid address %q", c.Sweep.To)
+	}
+	check(cashout.ValidAmount(c.Sweep.Keep), "sweep.keep", "invalid amount %q", c.Sweep.Keep)
+
+	check(c.Fund.Cashouts >= 1, "fund.cashouts", "must be at least 1")
+	_, err = cashout.ParseAmount(c.Fund.MaxPerAddress, etherDecimals)
+	check(err == nil, "fund.max_per_address", "%v", err)
+	_, err = cashout.ParseAmount(c.Fund.MaxTotal, etherDecimals)
+	check(err == nil, "fund.max_total", "%v", err)
+
+	if c.Daemon.Cron != "" {
+		_, err := cron.ParseStandard(c.Daemon.Cron)
+		check(err == nil, "daemon.cron", "%v", err)
+	} else if c.Daemon.Enabled {
+		check(c.Daemon.Interval > 0, "daemon.interval", "must be positive")
+	}
+	check(c.Daemon.Jitter >= 0, "daemon.jitter", "must not be negative")
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validRPC(endpoint string) bool {
+	if strings.HasSuffix(endpoint, ".ipc") {
+		return true
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+		return true
+	}
+	return false
+}
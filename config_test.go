@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cashout-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const contract = "0x0000000000000000000000000000000000000001"
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		args  []string
+		check func(c config) []interface{}
+		want  []interface{}
+	}{
+		{
+			name: "file overrides the default profile",
+			file: "eth:\n  chain_id: 97\n  network: http://10.0.0.1:8545\n",
+			check: func(c config) []interface{} {
+				return []interface{}{c.Eth.ChainID, c.Eth.Network, c.Eth.Endpoints, c.Eth.ContractAddress}
+			},
+			want: []interface{}{uint64(97), "http://10.0.0.1:8545", []string(nil), profiles[defaultProfile].ContractAddress},
+		},
+		{
+			name: "profile chosen in the file overrides the file",
+			file: "profile: devnet\nprofiles:\n  devnet:\n    endpoints: [http://10.0.0.2:8545]\n    chain_id: 1337\n    cheque_api: http://10.0.0.2:8080\n" +
+				"eth:\n  chain_id: 56\n  network: https://bsc-dataseed.binance.org\n  contract_address: \"" + contract + "\"\ncheque:\n  api: https://api.gpfs.xyz\n",
+			check: func(c config) []interface{} {
+				return []interface{}{c.Eth.ChainID, c.Eth.Network, c.Eth.Endpoints, c.Eth.ContractAddress, c.Cheque.API}
+			},
+			want: []interface{}{uint64(1337), "http://10.0.0.2:8545", []string{"http://10.0.0.2:8545"}, contract, "http://10.0.0.2:8080"},
+		},
+		{
+			name: "profile flag overrides the file",
+			file: "eth:\n  chain_id: 56\n  contract_address: \"" + contract + "\"\ndecimals: 18\n",
+			args: []string{"-profile", "local-dev"},
+			check: func(c config) []interface{} {
+				return []interface{}{c.Eth.ChainID, c.Eth.Network, c.Eth.ContractAddress, c.Decimals}
+			},
+			// local-dev defines no contract or decimals, so the file's stay
+			want: []interface{}{uint64(1337), "http://127.0.0.1:8545", contract, 18},
+		},
+		{
+			name: "profile from the environment overrides the file",
+			file: "profile: bsc-mainnet\neth:\n  chain_id: 56\n  contract_address: \"" + contract + "\"\n",
+			env:  map[string]string{"CASHOUT_PROFILE": "local-dev"},
+			check: func(c config) []interface{} {
+				return []interface{}{c.Eth.ChainID, c.Cheque.API}
+			},
+			want: []interface{}{uint64(1337), "http://127.0.0.1:8080"},
+		},
+		{
+			name: "environment overrides the profile, flags the environment",
+			file: "eth:\n  contract_address: \"" + contract + "\"\n",
+			env:  map[string]string{"CASHOUT_CHAIN_ID": "5", "CASHOUT_WORKERS": "3", "CASHOUT_NETWORK": "http://10.0.0.3:8545"},
+			args: []string{"-profile", "local-dev", "-chain_id", "7"},
+			check: func(c config) []interface{} {
+				return []interface{}{c.Eth.ChainID, c.Workers, c.Eth.Network, c.Eth.Endpoints}
+			},
+			want: []interface{}{uint64(7), 3, "http://10.0.0.3:8545", []string(nil)},
+		},
+		{
+			name: "endpoints flag replaces the profile's nodes",
+			args: []string{"-endpoints", "http://10.0.0.4:8545,http://10.0.0.5:8545"},
+			check: func(c config) []interface{} {
+				return []interface{}{c.Eth.Network, c.Eth.Endpoints}
+			},
+			want: []interface{}{"http://10.0.0.4:8545", []string{"http://10.0.0.4:8545", "http://10.0.0.5:8545"}},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := ""
+			if tt.file != "" {
+				filename = filepath.Join(dir, "config"+string(rune('a'+i))+".yaml")
+				if err := ioutil.WriteFile(filename, []byte(tt.file), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			c := defaultConfig()
+			fs := flag.NewFlagSet("cashout", flag.ContinueOnError)
+			bindFlags(fs, &c)
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			if err := loadConfig(fs, &c, filename, true); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.check(c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLoadConfigWithoutContract checks that commands without a contract, such
+// as keys and fake-api, run on profiles that define none.
+func TestLoadConfigWithoutContract(t *testing.T) {
+	for _, name := range []string{"local-dev", "bsc-testnet"} {
+		load := func(contract bool) error {
+			c := defaultConfig()
+			fs := flag.NewFlagSet("cashout", flag.ContinueOnError)
+			bindFlags(fs, &c)
+			if err := fs.Parse([]string{"-profile", name}); err != nil {
+				t.Fatal(err)
+			}
+			return loadConfig(fs, &c, "", contract)
+		}
+		if err := load(false); err != nil {
+			t.Errorf("%s without contract: %v", name, err)
+		}
+		if err := load(true); err == nil {
+			t.Errorf("%s with contract accepted an empty contract address", name)
+		}
+	}
+}
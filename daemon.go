@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/robfig/cron/v3"
+	"io"
+	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// schedule returns the time of the next cycle after t.
+type schedule func(t time.Time) time.Time
+
+// newSchedule builds a schedule from a standard five field cron expression
+// or, if cronSpec is empty, a fixed interval.
+func newSchedule(cronSpec string, interval time.Duration) (schedule, error) {
+	if cronSpec != "" {
+		s, err := cron.ParseStandard(cronSpec)
+		if err != nil {
+			return nil, err
+		}
+		return s.Next, nil
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %v", interval)
+	}
+	return func(t time.Time) time.Time { return t.Add(interval) }, nil
+}
+
+// signalContext returns a context that is cancelled on SIGINT or SIGTERM,
+// reporting the signal to msgs.
+func signalContext(msgs io.Writer) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-ch:
+			fmt.Fprintf(msgs, "received %v, shutting down\n", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(ch)
+	}()
+	return ctx, cancel
+}
+
+// runDaemon runs a cashout cycle on every tick of next, delayed by a random
+// jitter, until ctx is cancelled. A failed cycle is reported and the next one
+// runs as scheduled.
+func runDaemon(ctx context.Context, cycle func(context.Context) error, next schedule, jitter time.Duration, msgs io.Writer) {
+	for {
+		fmt.Fprintf(msgs, "cashout cycle started at %s\n", time.Now().Format(time.RFC3339))
+		if err := cycle(ctx); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(msgs, "cashout cycle failed, %v\n", err)
+		}
+
+		at := next(time.Now())
+		if jitter > 0 {
+			at = at.Add(time.Duration(rand.Int63n(int64(jitter))))
+		}
+		fmt.Fprintf(msgs, "next cycle at %s\n", at.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
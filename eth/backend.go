@@ -0,0 +1,37 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// Backend is what a Contract needs from the chain. *Pool implements it for
+// RPC endpoints and go-ethereum's simulated backend implements it for tests.
+type Backend interface {
+	bind.ContractBackend
+	bind.DeployBackend
+	BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error)
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error)
+	TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error)
+	PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error)
+}
+
+// ChainIDReader tells which chain a Backend is connected to. *Pool asks its
+// endpoints, StaticChainID is for backends that cannot, like the simulated
+// one.
+type ChainIDReader interface {
+	ChainID(ctx context.Context) (*big.Int, error)
+}
+
+// StaticChainID is a ChainIDReader returning a fixed chain ID.
+type StaticChainID uint64
+
+func (id StaticChainID) ChainID(context.Context) (*big.Int, error) {
+	return new(big.Int).SetUint64(uint64(id)), nil
+}
@@ -0,0 +1,96 @@
+package eth
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+)
+
+// EIP-712 domain of the GPSToken contract. Note that the contract does not
+// include a verifying contract address in its domain, only the chain ID.
+const (
+	DomainName    = "Chequebook"
+	DomainVersion = "1.0"
+)
+
+var (
+	EIP712DomainTypeHash = crypto.Keccak256Hash([]byte("EIP712Domain(string name,string version,uint256 chainId)"))
+	ChequeTypeHash       = crypto.Keccak256Hash([]byte("Cheque(address beneficiary,uint256 cumulativePayout)"))
+
+	ErrInvalidSignature = errors.New("invalid cheque signature")
+)
+
+// ChequeDigest returns the EIP-712 digest of a cheque, the hash GPSToken
+// recovers the issuer from in cashCheque.
+func ChequeDigest(chainId *big.Int, beneficiary common.Address, cumulativePayout *big.Int) common.Hash {
+	domainSeparator := crypto.Keccak256(
+		EIP712DomainTypeHash.Bytes(),
+		crypto.Keccak256([]byte(DomainName)),
+		crypto.Keccak256([]byte(DomainVersion)),
+		math.U256Bytes(new(big.Int).Set(chainId)),
+	)
+	chequeHash := crypto.Keccak256(
+		ChequeTypeHash.Bytes(),
+		common.LeftPadBytes(beneficiary.Bytes(), 32),
+		math.U256Bytes(new(big.Int).Set(cumulativePayout)),
+	)
+	return crypto.Keccak256Hash([]byte("\x19\x01"), domainSeparator, chequeHash)
+}
+
+// SignCheque signs a cheque as issuer key, in the 65 byte form with v of 27 or
+// 28 that cashCheque expects.
+func SignCheque(key *ecdsa.PrivateKey, chainId *big.Int, beneficiary common.Address, cumulativePayout *big.Int) ([]byte, error) {
+	digest := ChequeDigest(chainId, beneficiary, cumulativePayout)
+	sig, err := crypto.Sign(digest.Bytes(), key)
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	return sig, nil
+}
+
+// RecoverChequeSigner returns the address that signed a cheque. It applies the
+// same signature checks as the contract's ECDSA.recover: 65 bytes, v of 27 or
+// 28 and s in the lower half order.
+func RecoverChequeSigner(chainId *big.Int, beneficiary common.Address, cumulativePayout *big.Int, sig []byte) (common.Address, error) {
+	if len(sig) != 65 {
+		return common.Address{}, fmt.Errorf("%w: length %d", ErrInvalidSignature, len(sig))
+	}
+	v := sig[64]
+	if v != 27 && v != 28 {
+		return common.Address{}, fmt.Errorf("%w: v value %d", ErrInvalidSignature, v)
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if !crypto.ValidateSignatureValues(v-27, r, s, true) {
+		return common.Address{}, fmt.Errorf("%w: malleable or out of range", ErrInvalidSignature)
+	}
+
+	rsv := make([]byte, 65)
+	copy(rsv, sig)
+	rsv[64] = v - 27
+	digest := ChequeDigest(chainId, beneficiary, cumulativePayout)
+	pub, err := crypto.SigToPub(digest.Bytes(), rsv)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// VerifyCheque checks that sig is a cheque for beneficiary and
+// cumulativePayout signed by the contract owner, the only issuer cashCheque
+// accepts.
+func (c *Contract) VerifyCheque(beneficiary common.Address, cumulativePayout *big.Int, sig []byte) error {
+	signer, err := RecoverChequeSigner(c.chainId, beneficiary, cumulativePayout, sig)
+	if err != nil {
+		return err
+	}
+	if signer != c.issuer {
+		return fmt.Errorf("%w: signed by %s, issuer is %s", ErrInvalidSignature, signer.Hex(), c.issuer.Hex())
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package eth
+
+import (
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"math/big"
+	"testing"
+)
+
+// typedDataDigest hashes a cheque with go-ethereum's generic EIP-712
+// implementation, to check ChequeDigest against.
+func typedDataDigest(t *testing.T, chainId *big.Int, beneficiary common.Address, cumulativePayout *big.Int) common.Hash {
+	typedData := core.TypedData{
+		Types: core.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"Cheque": {
+				{Name: "beneficiary", Type: "address"},
+				{Name: "cumulativePayout", Type: "uint256"},
+			},
+		},
+		PrimaryType: "Cheque",
+		Domain: core.TypedDataDomain{
+			Name:    DomainName,
+			Version: DomainVersion,
+			ChainId: (*math.HexOrDecimal256)(chainId),
+		},
+		Message: core.TypedDataMessage{
+			"beneficiary":      beneficiary.Hex(),
+			"cumulativePayout": (*math.HexOrDecimal256)(cumulativePayout),
+		},
+	}
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		t.Fatal(err)
+	}
+	chequeHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return crypto.Keccak256Hash([]byte("\x19\x01"), domainSeparator, chequeHash)
+}
+
+func TestChequeDigest(t *testing.T) {
+	tests := []struct {
+		chainId          int64
+		beneficiary      string
+		cumulativePayout string
+	}{
+		{56, "0x664e01fc0f9a5dc2e814af517dce25071525544f", "2365437062"},
+		{97, "0x0000000000000000000000000000000000000001", "0"},
+		{1337, "0xffffffffffffffffffffffffffffffffffffffff", "115792089237316195423570985008687907853269984665640564039457584007913129639935"},
+	}
+	for _, tt := range tests {
+		chainId := big.NewInt(tt.chainId)
+		beneficiary := common.HexToAddress(tt.beneficiary)
+		cumulativePayout, _ := new(big.Int).SetString(tt.cumulativePayout, 10)
+		got := ChequeDigest(chainId, beneficiary, cumulativePayout)
+		if want := typedDataDigest(t, chainId, beneficiary, cumulativePayout); got != want {
+			t.Errorf("ChequeDigest(%d, %s, %s) = %s, want %s", tt.chainId, tt.beneficiary, tt.cumulativePayout, got.Hex(), want.Hex())
+		}
+	}
+}
+
+func TestRecoverChequeSigner(t *testing.T) {
+	key, err := crypto.HexToECDSA("77dd33ed201813038b5c9a33b9eb0d4a07c3b83bd88e709e40228b762feedecd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := crypto.PubkeyToAddress(key.PublicKey)
+	chainId := big.NewInt(56)
+	beneficiary := common.HexToAddress("0x664e01fc0f9a5dc2e814af517dce25071525544f")
+	amount := big.NewInt(2365437062)
+	sig, err := SignCheque(key, chainId, beneficiary, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	with := func(change func(sig []byte) []byte) []byte {
+		return change(append([]byte(nil), sig...))
+	}
+	// the other of the two valid s values, (r, n-s) with flipped v
+	n := crypto.S256().Params().N
+	highS := with(func(sig []byte) []byte {
+		s := new(big.Int).Sub(n, new(big.Int).SetBytes(sig[32:64]))
+		copy(sig[32:64], common.LeftPadBytes(s.Bytes(), 32))
+		sig[64] ^= 27 ^ 28
+		return sig
+	})
+
+	tests := []struct {
+		name    string
+		chainId *big.Int
+		amount  *big.Int
+		sig     []byte
+		signer  common.Address
+		err     bool
+	}{
+		{name: "valid", chainId: chainId, amount: amount, sig: sig, signer: issuer},
+		{name: "other amount", chainId: chainId, amount: big.NewInt(1), sig: sig},
+		{name: "other chain", chainId: big.NewInt(97), amount: amount, sig: sig},
+		{name: "v of 0 or 1", chainId: chainId, amount: amount, sig: with(func(sig []byte) []byte { sig[64] -= 27; return sig }), err: true},
+		{name: "short", chainId: chainId, amount: amount, sig: sig[:64], err: true},
+		{name: "high s", chainId: chainId, amount: amount, sig: highS, err: true},
+	}
+	for _, tt := range tests {
+		signer, err := RecoverChequeSigner(tt.chainId, beneficiary, tt.amount, tt.sig)
+		switch {
+		case tt.err:
+			if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("%s: got %s, %v, want ErrInvalidSignature", tt.name, signer.Hex(), err)
+			}
+		case err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.signer != (common.Address{}) && signer != tt.signer:
+			t.Errorf("%s: recovered %s, want %s", tt.name, signer.Hex(), tt.signer.Hex())
+		case tt.signer == (common.Address{}) && signer == issuer:
+			t.Errorf("%s: recovered the issuer from a cheque it did not sign", tt.name)
+		}
+	}
+}
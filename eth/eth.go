@@ -2,58 +2,146 @@ package eth
 
 import (
 	"context"
-	"crypto/ecdsa"
+	"errors"
+	"fmt"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/ethclient"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/zhaozilong88/cashout/eth/gps"
 	"math/big"
+	"time"
 )
 
 var log = logging.Logger("eth")
 
 type Config struct {
-	Network         string `yaml:"network"`
-	ContractAddress string `yaml:"contract_address"`
-	GasLimit        uint64 `yaml:"gas_limit"`
-	GasPrice        int64  `yaml:"gas_limit"`
+	Network string `yaml:"network"`
+	// Endpoints are additional RPC endpoints to fail over to.
+	Endpoints []string `yaml:"endpoints"`
+	// MaxBlockAge is how old the latest block of an endpoint may be before
+	// it counts as unhealthy.
+	MaxBlockAge     time.Duration `yaml:"max_block_age"`
+	ContractAddress string        `yaml:"contract_address"`
+	// ChainID is the chain NewContract expects to connect to, 0 accepts any.
+	ChainID  uint64 `yaml:"chain_id"`
+	GasLimit uint64 `yaml:"gas_limit"`
+	GasPrice int64  `yaml:"gas_price"`
+	// Confirmations is the number of blocks, including the one containing
+	// the transaction, to wait for before a cashout counts as final.
+	Confirmations  uint64        `yaml:"confirmations"`
+	ReceiptTimeout time.Duration `yaml:"receipt_timeout"`
+	// GasStrategy is GasFixed or GasAuto. With GasAuto a zero GasLimit is
+	// estimated and a zero GasPrice comes from the gas price oracle.
+	GasStrategy        string  `yaml:"gas_strategy"`
+	GasMultiplier      float64 `yaml:"gas_multiplier"`
+	GasPricePercentile int     `yaml:"gas_price_percentile"`
+	GasPriceBlocks     int     `yaml:"gas_price_blocks"`
+	MaxGasPrice        int64   `yaml:"max_gas_price"`
+}
+
+// endpoints returns Network followed by the other Endpoints.
+func (conf Config) endpoints() []string {
+	var urls []string
+	if conf.Network != "" {
+		urls = append(urls, conf.Network)
+	}
+	for _, url := range conf.Endpoints {
+		if url != conf.Network {
+			urls = append(urls, url)
+		}
+	}
+	return urls
 }
 
 type Contract struct {
-	conf       Config
-	token      *gps.GPSToken
-	chainId    *big.Int
-	privateKey *ecdsa.PrivateKey
+	conf     Config
+	client   Backend
+	address  common.Address
+	token    *gps.GPSToken
+	chainId  *big.Int
+	issuer   common.Address
+	decimals uint8
+	oracle   *gasOracle
+	nonces   *NonceManager
 }
 
+// NewContract connects to the RPC endpoints of conf.
 func NewContract(conf Config) (*Contract, error) {
-	client, err := ethclient.Dial(conf.Network)
+	client, err := NewPool(context.Background(), conf.endpoints(), conf.ChainID, conf.MaxBlockAge)
 	if err != nil {
 		log.Errorf("Failed to connect to eth: %v", err)
 		return nil, err
 	}
-	chainId, err := client.ChainID(context.Background())
+	return NewContractWithBackend(conf, client, client)
+}
+
+// NewContractWithBackend uses client instead of dialing conf.Network, the
+// chain ID comes from chain. The endpoint settings of conf are ignored.
+func NewContractWithBackend(conf Config, client Backend, chain ChainIDReader) (*Contract, error) {
+	chainId, err := chain.ChainID(context.Background())
 	if err != nil {
 		log.Errorf("Failed to get chainId: %v", err)
 		return nil, err
 	}
-	token, err := gps.NewGPSToken(common.HexToAddress(conf.ContractAddress), client)
+	if conf.ChainID != 0 && chainId.Uint64() != conf.ChainID {
+		err := fmt.Errorf("connected to chain %v, expected %d", chainId, conf.ChainID)
+		log.Errorf("Wrong network: %v", err)
+		return nil, err
+	}
+	address := common.HexToAddress(conf.ContractAddress)
+	token, err := gps.NewGPSToken(address, client)
 	if err != nil {
 		log.Errorf("Failed to instantiate a Token contract: %v", err)
 		return nil, err
 	}
+	if err := checkTypeHashes(token); err != nil {
+		log.Errorf("Failed to check cheque type hashes: %v", err)
+		return nil, err
+	}
+	issuer, err := token.Owner(nil)
+	if err != nil {
+		log.Errorf("Failed to get cheque issuer: %v", err)
+		return nil, err
+	}
+	decimals, err := token.Decimals(nil)
+	if err != nil {
+		log.Errorf("Failed to get token decimals: %v", err)
+		return nil, err
+	}
 
 	return &Contract{
-		conf:    conf,
-		token:   token,
-		chainId: chainId,
+		conf:     conf,
+		oracle:   newGasOracle(client, conf),
+		nonces:   newNonceManager(client),
+		client:   client,
+		address:  address,
+		token:    token,
+		chainId:  chainId,
+		issuer:   issuer,
+		decimals: decimals,
 	}, nil
 }
 
-func (c *Contract) GetPaidOut(addr string) (*big.Int, error) {
-	amount, err := c.token.PaidOut(nil, common.HexToAddress(addr))
+// checkTypeHashes makes sure the deployed contract hashes cheques the same
+// way ChequeDigest does.
+func checkTypeHashes(token *gps.GPSToken) error {
+	domainTypeHash, err := token.EIP712DOMAINTYPEHASH(nil)
+	if err != nil {
+		return err
+	}
+	chequeTypeHash, err := token.CHEQUETYPEHASH(nil)
+	if err != nil {
+		return err
+	}
+	if domainTypeHash != EIP712DomainTypeHash || chequeTypeHash != ChequeTypeHash {
+		return errors.New("contract uses unknown EIP-712 type hashes")
+	}
+	return nil
+}
+
+func (c *Contract) GetPaidOut(ctx context.Context, addr string) (*big.Int, error) {
+	amount, err := c.token.PaidOut(&bind.CallOpts{Context: ctx}, common.HexToAddress(addr))
 	if err != nil {
 		log.Errorf("failed to get Accounts, %v", err)
 		return amount, err
@@ -61,19 +149,127 @@ func (c *Contract) GetPaidOut(addr string) (*big.Int, error) {
 	return amount, nil
 }
 
-func (c *Contract) Cashout(privateKey *ecdsa.PrivateKey, cumulativePayout *big.Int, issuerSig []byte) (*types.Transaction, error) {
-	opt, err := bind.NewKeyedTransactorWithChainID(privateKey, c.chainId)
+// BeforeSend is called with a signed transaction right before it is
+// broadcast. Returning an error aborts the broadcast.
+type BeforeSend func(tx *types.Transaction) error
+
+func (c *Contract) Cashout(ctx context.Context, signer Signer, cumulativePayout *big.Int, issuerSig []byte, beforeSend BeforeSend) (*types.Transaction, error) {
+	tx, err := c.transact(ctx, signer, beforeSend, "cashCheque", cumulativePayout, issuerSig)
 	if err != nil {
 		log.Errorf("failed to cashout, %v", err)
-		return nil, err
+		return tx, err
 	}
-	opt.GasLimit = c.conf.GasLimit
-	opt.GasPrice = big.NewInt(c.conf.GasPrice * (1000_000_000))
+	return tx, nil
+}
 
-	tx, err := c.token.CashCheque(opt, cumulativePayout, issuerSig)
+// BalanceOf returns the GPS token balance of addr.
+func (c *Contract) BalanceOf(ctx context.Context, addr common.Address) (*big.Int, error) {
+	amount, err := c.token.BalanceOf(&bind.CallOpts{Context: ctx}, addr)
 	if err != nil {
-		log.Errorf("failed to cashout, %v", err)
+		log.Errorf("failed to get balance, %v", err)
+		return amount, err
+	}
+	return amount, nil
+}
+
+// Transfer sends amount GPS tokens from signer to to.
+func (c *Contract) Transfer(ctx context.Context, signer Signer, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	tx, err := c.transact(ctx, signer, nil, "transfer", to, amount)
+	if err != nil {
+		log.Errorf("failed to transfer, %v", err)
 		return tx, err
 	}
 	return tx, nil
 }
+
+// transact calls a GPSToken method from signer with the configured gas
+// settings and a nonce from the nonce manager.
+func (c *Contract) transact(ctx context.Context, signer Signer, beforeSend BeforeSend, method string, params ...interface{}) (*types.Transaction, error) {
+	opt := c.transactOpts(ctx, signer)
+	if beforeSend != nil {
+		signer := opt.Signer
+		opt.Signer = func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			signed, err := signer(from, tx)
+			if err != nil {
+				return nil, err
+			}
+			return signed, beforeSend(signed)
+		}
+	}
+	var err error
+	opt.GasPrice, err = c.gasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	opt.GasLimit, err = c.gasLimit(ctx, opt.From, opt.GasPrice, method, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := &gps.GPSTokenRaw{Contract: c.token}
+	return c.nonces.send(opt, func(opt *bind.TransactOpts) (*types.Transaction, error) {
+		return raw.Transact(opt, method, params...)
+	})
+}
+
+// Decimals returns the number of decimals of the token, read from the
+// contract when c was created.
+func (c *Contract) Decimals() uint8 {
+	return c.decimals
+}
+
+// ResyncNonce drops the local nonce of addr, see NonceManager.Resync.
+func (c *Contract) ResyncNonce(addr common.Address) {
+	c.nonces.Resync(addr)
+}
+
+// EndpointStats returns the call and error counters of the RPC endpoints,
+// nil if c does not use a Pool.
+func (c *Contract) EndpointStats() []EndpointStats {
+	if pool, ok := c.client.(*Pool); ok {
+		return pool.Stats()
+	}
+	return nil
+}
+
+// NonceManager returns the nonce manager used for all transactions of c.
+func (c *Contract) NonceManager() *NonceManager {
+	return c.nonces
+}
+
+// TokenInfo is the metadata of the GPSToken contract.
+type TokenInfo struct {
+	Address     common.Address
+	ChainID     *big.Int
+	Name        string
+	Symbol      string
+	Decimals    uint8
+	TotalSupply *big.Int
+	MaxSupply   *big.Int
+	Issuer      common.Address
+}
+
+// Info reads the metadata of the contract.
+func (c *Contract) Info(ctx context.Context) (*TokenInfo, error) {
+	opts := &bind.CallOpts{Context: ctx}
+	info := &TokenInfo{
+		Address:  c.address,
+		ChainID:  c.chainId,
+		Decimals: c.decimals,
+		Issuer:   c.issuer,
+	}
+	var err error
+	if info.Name, err = c.token.Name(opts); err != nil {
+		return nil, err
+	}
+	if info.Symbol, err = c.token.Symbol(opts); err != nil {
+		return nil, err
+	}
+	if info.TotalSupply, err = c.token.TotalSupply(opts); err != nil {
+		return nil, err
+	}
+	if info.MaxSupply, err = c.token.MaxSupply(opts); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
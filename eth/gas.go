@@ -0,0 +1,158 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	GasFixed = "fixed"
+	GasAuto  = "auto"
+
+	// DefaultGasLimit and DefaultGasPrice (in Gwei) apply to the fixed
+	// strategy when no gas limit or price is configured.
+	DefaultGasLimit = 100000
+	DefaultGasPrice = 5
+
+	defaultGasMultiplier  = 1.2
+	defaultGasPriceBlocks = 20
+	gasPriceCacheTime     = 30 * time.Second
+)
+
+var gwei = big.NewInt(1000_000_000)
+
+func (c *Contract) auto() bool {
+	return c.conf.GasStrategy == GasAuto
+}
+
+// gasPrice returns the configured gas price or, with the auto strategy and no
+// fixed price, the oracle price capped at MaxGasPrice.
+func (c *Contract) gasPrice(ctx context.Context) (*big.Int, error) {
+	if c.conf.GasPrice > 0 {
+		return new(big.Int).Mul(big.NewInt(c.conf.GasPrice), gwei), nil
+	}
+	if !c.auto() {
+		return new(big.Int).Mul(big.NewInt(DefaultGasPrice), gwei), nil
+	}
+	price, err := c.oracle.price(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if c.conf.MaxGasPrice > 0 {
+		max := new(big.Int).Mul(big.NewInt(c.conf.MaxGasPrice), gwei)
+		if price.Cmp(max) > 0 {
+			log.Warnf("gas price %v capped at %v", price, max)
+			price = max
+		}
+	}
+	return price, nil
+}
+
+// gasLimit returns the configured gas limit or, with the auto strategy and no
+// fixed limit, the estimate for calling method scaled by GasMultiplier.
+func (c *Contract) gasLimit(ctx context.Context, from common.Address, gasPrice *big.Int, method string, args ...interface{}) (uint64, error) {
+	if c.conf.GasLimit > 0 {
+		return c.conf.GasLimit, nil
+	}
+	if !c.auto() {
+		return DefaultGasLimit, nil
+	}
+	data, err := tokenABI.Pack(method, args...)
+	if err != nil {
+		return 0, err
+	}
+	gas, err := c.client.EstimateGas(ctx, ethereum.CallMsg{
+		From:     from,
+		To:       &c.address,
+		GasPrice: gasPrice,
+		Data:     data,
+	})
+	if err != nil {
+		return 0, revertError(err)
+	}
+	multiplier := c.conf.GasMultiplier
+	if multiplier < 1 {
+		multiplier = defaultGasMultiplier
+	}
+	return uint64(float64(gas) * multiplier), nil
+}
+
+// gasOracle suggests gas prices, either the node's eth_gasPrice or a
+// percentile of the prices paid in recent blocks. Results are cached briefly
+// so a run over many keys does not query the node for every transaction.
+type gasOracle struct {
+	client     Backend
+	percentile int
+	blocks     int
+
+	mu      sync.Mutex
+	last    *big.Int
+	updated time.Time
+}
+
+func newGasOracle(client Backend, conf Config) *gasOracle {
+	blocks := conf.GasPriceBlocks
+	if blocks <= 0 {
+		blocks = defaultGasPriceBlocks
+	}
+	return &gasOracle{
+		client:     client,
+		percentile: conf.GasPricePercentile,
+		blocks:     blocks,
+	}
+}
+
+func (o *gasOracle) price(ctx context.Context) (*big.Int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.last != nil && time.Since(o.updated) < gasPriceCacheTime {
+		return new(big.Int).Set(o.last), nil
+	}
+
+	var price *big.Int
+	var err error
+	if o.percentile > 0 {
+		price, err = o.recentPrice(ctx)
+	} else {
+		price, err = o.client.SuggestGasPrice(ctx)
+	}
+	if err != nil {
+		log.Errorf("failed to get gas price, %v", err)
+		return nil, err
+	}
+	o.last, o.updated = price, time.Now()
+	return new(big.Int).Set(price), nil
+}
+
+// recentPrice returns the configured percentile of the gas prices of the
+// transactions in the latest blocks.
+func (o *gasOracle) recentPrice(ctx context.Context) (*big.Int, error) {
+	head, err := o.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var prices []*big.Int
+	for i := 0; i < o.blocks && head.Number.Int64()-int64(i) >= 0; i++ {
+		block, err := o.client.BlockByNumber(ctx, new(big.Int).Sub(head.Number, big.NewInt(int64(i))))
+		if err != nil {
+			return nil, err
+		}
+		for _, tx := range block.Transactions() {
+			prices = append(prices, tx.GasPrice())
+		}
+	}
+	if len(prices) == 0 {
+		return o.client.SuggestGasPrice(ctx)
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i].Cmp(prices[j]) < 0 })
+	percentile := o.percentile
+	if percentile > 100 {
+		percentile = 100
+	}
+	return prices[(len(prices)-1)*percentile/100], nil
+}
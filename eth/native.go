@@ -0,0 +1,79 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// transferGas is the gas used by a plain value transfer.
+const transferGas = 21000
+
+// NativeBalance returns the BNB balance of addr in wei.
+func (c *Contract) NativeBalance(ctx context.Context, addr common.Address) (*big.Int, error) {
+	return c.client.BalanceAt(ctx, addr, nil)
+}
+
+// CashoutCost returns the fee in wei a cashout may cost at the current gas
+// price. Without a cheque the gas of cashCheque cannot be estimated, so the
+// configured gas limit or DefaultGasLimit is used.
+func (c *Contract) CashoutCost(ctx context.Context) (*big.Int, error) {
+	price, err := c.gasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit := c.conf.GasLimit
+	if limit == 0 {
+		limit = DefaultGasLimit
+	}
+	return new(big.Int).Mul(price, new(big.Int).SetUint64(limit)), nil
+}
+
+// EstimateCashoutCost returns the fee in wei of the cashout Cashout would
+// send from beneficiary. With the auto strategy the gas is estimated for the
+// actual cheque, so it fails like the cashout would.
+func (c *Contract) EstimateCashoutCost(ctx context.Context, beneficiary common.Address, cumulativePayout *big.Int, issuerSig []byte) (*big.Int, error) {
+	price, err := c.gasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := c.gasLimit(ctx, beneficiary, price, "cashCheque", cumulativePayout, issuerSig)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Mul(price, new(big.Int).SetUint64(limit)), nil
+}
+
+// TransferCost returns the fee in wei of a plain value transfer at the
+// current gas price.
+func (c *Contract) TransferCost(ctx context.Context) (*big.Int, error) {
+	price, err := c.gasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).Mul(price, big.NewInt(transferGas)), nil
+}
+
+// SendValue sends amount wei from signer to to.
+func (c *Contract) SendValue(ctx context.Context, signer Signer, to common.Address, amount *big.Int) (*types.Transaction, error) {
+	opt := c.transactOpts(ctx, signer)
+	var err error
+	opt.GasPrice, err = c.gasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := c.nonces.send(opt, func(opt *bind.TransactOpts) (*types.Transaction, error) {
+		signed, err := opt.Signer(opt.From, types.NewTransaction(opt.Nonce.Uint64(), to, amount, transferGas, opt.GasPrice, nil))
+		if err != nil {
+			return nil, err
+		}
+		return signed, c.client.SendTransaction(ctx, signed)
+	})
+	if err != nil {
+		log.Errorf("failed to send value, %v", err)
+		return tx, err
+	}
+	return tx, nil
+}
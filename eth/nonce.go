@@ -0,0 +1,111 @@
+package eth
+
+import (
+	"context"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// maxNonceRetries bounds how often a send is retried after resyncing a nonce
+// the node rejected.
+const maxNonceRetries = 3
+
+// NonceManager allocates nonces locally per address, so several transactions
+// from one key in a run get consecutive nonces instead of all asking the node
+// for the same pending nonce.
+type NonceManager struct {
+	backend interface {
+		PendingNonceAt(ctx context.Context, account common.Address) (uint64, error)
+	}
+
+	mu    sync.Mutex
+	slots map[common.Address]*nonceSlot
+}
+
+type nonceSlot struct {
+	// mu is held while a transaction from the address is signed and sent
+	mu    sync.Mutex
+	next  uint64
+	known bool
+}
+
+func newNonceManager(backend Backend) *NonceManager {
+	return &NonceManager{backend: backend, slots: map[common.Address]*nonceSlot{}}
+}
+
+func (m *NonceManager) slot(addr common.Address) *nonceSlot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.slots[addr]
+	if !ok {
+		s = new(nonceSlot)
+		m.slots[addr] = s
+	}
+	return s
+}
+
+// Resync drops the local nonce of addr, the next transaction asks the node
+// again.
+func (m *NonceManager) Resync(addr common.Address) {
+	s := m.slot(addr)
+	s.mu.Lock()
+	s.known = false
+	s.mu.Unlock()
+}
+
+// send signs and broadcasts a transaction from opt.From with the next local
+// nonce. A nonce the node considers used is resynced and the send retried; a
+// transaction the node already has counts as sent.
+func (m *NonceManager) send(opt *bind.TransactOpts, transact func(*bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
+	s := m.slot(opt.From)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var signed *types.Transaction
+	signer := opt.Signer
+	opt.Signer = func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		var err error
+		signed, err = signer(from, tx)
+		return signed, err
+	}
+
+	for retry := 0; ; retry++ {
+		if !s.known {
+			ctx := opt.Context
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			nonce, err := m.backend.PendingNonceAt(ctx, opt.From)
+			if err != nil {
+				return nil, err
+			}
+			s.next, s.known = nonce, true
+		}
+		opt.Nonce = new(big.Int).SetUint64(s.next)
+		signed = nil
+
+		tx, err := transact(opt)
+		switch {
+		case err == nil:
+			s.next++
+			return tx, nil
+		case signed != nil && isKnownTx(err):
+			s.next++
+			return signed, nil
+		case isNonceTaken(err) && retry < maxNonceRetries:
+			log.Warnf("nonce %d of %s is taken, resyncing: %v", s.next, opt.From.Hex(), err)
+			s.known = false
+		default:
+			return nil, err
+		}
+	}
+}
+
+func isNonceTaken(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "nonce too low") || strings.Contains(msg, "replacement transaction underpriced")
+}
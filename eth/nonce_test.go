@@ -0,0 +1,125 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+	"testing"
+)
+
+// pendingNonces answers PendingNonceAt with the next of nonces.
+type pendingNonces struct {
+	nonces []uint64
+	calls  int
+}
+
+func (b *pendingNonces) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	b.calls++
+	if len(b.nonces) == 0 {
+		return 0, errors.New("no nonce left")
+	}
+	n := b.nonces[0]
+	b.nonces = b.nonces[1:]
+	return n, nil
+}
+
+// sender is a transact function that signs a transaction with opt.Nonce and
+// fails with the next of errs, recording the nonces it was called with.
+type sender struct {
+	errs   []error
+	nonces []uint64
+}
+
+func (s *sender) transact(opt *bind.TransactOpts) (*types.Transaction, error) {
+	s.nonces = append(s.nonces, opt.Nonce.Uint64())
+	tx, err := opt.Signer(opt.From, types.NewTransaction(opt.Nonce.Uint64(), common.Address{}, new(big.Int), 21000, new(big.Int), nil))
+	if err != nil {
+		return nil, err
+	}
+	if len(s.errs) > 0 {
+		err, s.errs = s.errs[0], s.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// testOpts returns options of from that leave transactions unsigned.
+func testOpts(from common.Address) *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From:   from,
+		Signer: func(_ common.Address, tx *types.Transaction) (*types.Transaction, error) { return tx, nil },
+	}
+}
+
+func TestNonceManagerSend(t *testing.T) {
+	from := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	tooLow := errors.New("nonce too low")
+	tests := []struct {
+		name    string
+		pending []uint64
+		errs    []error // of the sends, one per transact call
+		sends   int
+		want    []uint64 // nonces transact was called with
+		lookups int
+		failed  bool
+	}{
+		{"consecutive", []uint64{5}, nil, 2, []uint64{5, 6}, 1, false},
+		{"resyncs a taken nonce", []uint64{5, 8}, []error{tooLow}, 2, []uint64{5, 8, 9}, 2, false},
+		{"known counts as sent", []uint64{5}, []error{errors.New("already known")}, 2, []uint64{5, 6}, 1, false},
+		{"underpriced replacement resyncs", []uint64{5, 6}, []error{errors.New("replacement transaction underpriced")}, 1, []uint64{5, 6}, 2, false},
+		{"gives up", []uint64{5, 5, 5, 5}, []error{tooLow, tooLow, tooLow, tooLow}, 1, []uint64{5, 5, 5, 5}, maxNonceRetries + 1, true},
+		{"other errors are final", []uint64{5}, []error{errors.New("insufficient funds")}, 1, []uint64{5}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &pendingNonces{nonces: tt.pending}
+			m := &NonceManager{backend: backend, slots: map[common.Address]*nonceSlot{}}
+			s := &sender{errs: tt.errs}
+			var err error
+			for i := 0; i < tt.sends && err == nil; i++ {
+				var tx *types.Transaction
+				tx, err = m.send(testOpts(from), s.transact)
+				if err == nil && tx.Nonce() != s.nonces[len(s.nonces)-1] {
+					t.Errorf("send returned nonce %d, sent %d", tx.Nonce(), s.nonces[len(s.nonces)-1])
+				}
+			}
+			if (err != nil) != tt.failed {
+				t.Fatalf("send error %v, want failure %v", err, tt.failed)
+			}
+			if len(s.nonces) != len(tt.want) {
+				t.Fatalf("sent nonces %v, want %v", s.nonces, tt.want)
+			}
+			for i := range tt.want {
+				if s.nonces[i] != tt.want[i] {
+					t.Fatalf("sent nonces %v, want %v", s.nonces, tt.want)
+				}
+			}
+			if backend.calls != tt.lookups {
+				t.Errorf("asked the node %d times, want %d", backend.calls, tt.lookups)
+			}
+		})
+	}
+}
+
+func TestNonceManagerResync(t *testing.T) {
+	from := common.HexToAddress("0x0000000000000000000000000000000000000001")
+	backend := &pendingNonces{nonces: []uint64{3, 3}}
+	m := &NonceManager{backend: backend, slots: map[common.Address]*nonceSlot{}}
+	s := &sender{}
+	if _, err := m.send(testOpts(from), s.transact); err != nil {
+		t.Fatal(err)
+	}
+	// the transaction with nonce 3 was dropped, the node hands it out again
+	m.Resync(from)
+	if _, err := m.send(testOpts(from), s.transact); err != nil {
+		t.Fatal(err)
+	}
+	if s.nonces[1] != 3 || backend.calls != 2 {
+		t.Errorf("sent nonces %v after %d lookups, want 3 again after 2", s.nonces, backend.calls)
+	}
+}
@@ -0,0 +1,340 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	healthCheckInterval = 30 * time.Second
+	healthCheckTimeout  = 5 * time.Second
+	defaultMaxBlockAge  = 2 * time.Minute
+)
+
+// EndpointStats are the counters of one RPC endpoint.
+type EndpointStats struct {
+	URL     string
+	Healthy bool
+	Latency time.Duration
+	Calls   uint64
+	Errors  uint64
+}
+
+type endpoint struct {
+	url    string
+	client *ethclient.Client
+	calls  uint64
+	errors uint64
+
+	// guarded by Pool.mu
+	healthy bool
+	latency time.Duration
+	// wrongChain endpoints are on another chain and never used again
+	wrongChain bool
+}
+
+// Pool routes RPC calls to the healthiest of several endpoints and fails over
+// to the next one when an endpoint cannot be reached. Errors returned by a
+// node, like reverts or unknown transactions, are final and not retried
+// elsewhere.
+type Pool struct {
+	endpoints   []*endpoint
+	chainId     uint64
+	maxBlockAge time.Duration
+
+	mu       sync.Mutex
+	order    []*endpoint
+	checked  time.Time
+	checking bool
+}
+
+// NewPool dials every url and runs a first health check. chainId, if not 0,
+// drops endpoints on other chains for good; maxBlockAge marks endpoints whose
+// latest block is older unhealthy, so they are only tried last.
+func NewPool(ctx context.Context, urls []string, chainId uint64, maxBlockAge time.Duration) (*Pool, error) {
+	if len(urls) == 0 {
+		return nil, errors.New("no RPC endpoint configured")
+	}
+	if maxBlockAge <= 0 {
+		maxBlockAge = defaultMaxBlockAge
+	}
+	p := &Pool{chainId: chainId, maxBlockAge: maxBlockAge}
+	for _, url := range urls {
+		client, err := ethclient.Dial(url)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", url, err)
+		}
+		p.endpoints = append(p.endpoints, &endpoint{url: url, client: client})
+	}
+	p.healthCheck(ctx)
+	return p, nil
+}
+
+// health is the outcome of checking one endpoint.
+type health struct {
+	healthy    bool
+	latency    time.Duration
+	wrongChain bool
+}
+
+// healthCheck measures every endpoint and orders them healthy first, then by
+// latency, leaving out endpoints on the wrong chain. The endpoints are probed
+// without holding p.mu, so calls keep using the previous order meanwhile.
+func (p *Pool) healthCheck(ctx context.Context) {
+	p.mu.Lock()
+	var probe []*endpoint
+	for _, e := range p.endpoints {
+		if !e.wrongChain {
+			probe = append(probe, e)
+		}
+	}
+	p.mu.Unlock()
+
+	results := make([]health, len(probe))
+	var wg sync.WaitGroup
+	for i, e := range probe {
+		wg.Add(1)
+		go func(i int, e *endpoint) {
+			defer wg.Done()
+			results[i] = p.check(ctx, e)
+		}(i, e)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, e := range probe {
+		e.healthy, e.latency, e.wrongChain = results[i].healthy, results[i].latency, results[i].wrongChain
+	}
+	var order []*endpoint
+	for _, e := range p.endpoints {
+		if !e.wrongChain {
+			order = append(order, e)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if order[i].healthy != order[j].healthy {
+			return order[i].healthy
+		}
+		return order[i].latency < order[j].latency
+	})
+	p.order = order
+	p.checked = time.Now()
+	p.checking = false
+}
+
+func (p *Pool) check(ctx context.Context, e *endpoint) health {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	atomic.AddUint64(&e.calls, 1)
+	chainId, err := e.client.ChainID(ctx)
+	if err != nil {
+		atomic.AddUint64(&e.errors, 1)
+		log.Warnf("endpoint %s unhealthy: %v", e.url, err)
+		return health{}
+	}
+	latency := time.Since(start)
+	if p.chainId != 0 && chainId.Uint64() != p.chainId {
+		log.Warnf("endpoint %s dropped: chain %v, expected %d", e.url, chainId, p.chainId)
+		return health{latency: latency, wrongChain: true}
+	}
+	atomic.AddUint64(&e.calls, 1)
+	head, err := e.client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		atomic.AddUint64(&e.errors, 1)
+		log.Warnf("endpoint %s unhealthy: %v", e.url, err)
+		return health{latency: latency}
+	}
+	if age := time.Since(time.Unix(int64(head.Time), 0)); age > p.maxBlockAge {
+		log.Warnf("endpoint %s unhealthy: latest block is %v old", e.url, age.Round(time.Second))
+		return health{latency: latency}
+	}
+	return health{healthy: true, latency: latency}
+}
+
+// candidates returns the endpoints in the order they should be tried,
+// refreshing the health check when it is due. The check runs on a background
+// context, it is shared by every caller and must not fail because the caller
+// that happened to trigger it was cancelled.
+func (p *Pool) candidates() []*endpoint {
+	p.mu.Lock()
+	due := !p.checking && time.Since(p.checked) > healthCheckInterval
+	if due {
+		p.checking = true
+	}
+	p.mu.Unlock()
+	if due {
+		p.healthCheck(context.Background())
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.order
+}
+
+func (p *Pool) markUnhealthy(e *endpoint) {
+	p.mu.Lock()
+	e.healthy = false
+	p.mu.Unlock()
+}
+
+// do runs call on the preferred endpoint, failing over to the others while
+// the error is a transport error.
+func (p *Pool) do(ctx context.Context, call func(*ethclient.Client) error) error {
+	candidates := p.candidates()
+	if len(candidates) == 0 {
+		return fmt.Errorf("no RPC endpoint on chain %d", p.chainId)
+	}
+	var err error
+	for _, e := range candidates {
+		atomic.AddUint64(&e.calls, 1)
+		err = call(e.client)
+		if !retryable(ctx, err) {
+			return err
+		}
+		atomic.AddUint64(&e.errors, 1)
+		p.markUnhealthy(e)
+		log.Warnf("endpoint %s failed, %v", e.url, err)
+	}
+	return err
+}
+
+// retryable reports whether err means the endpoint could not answer, as
+// opposed to an answer that would be the same on any node.
+func retryable(ctx context.Context, err error) bool {
+	if err == nil || err == ethereum.NotFound || ctx.Err() != nil {
+		return false
+	}
+	var rpcErr rpc.Error
+	return !errors.As(err, &rpcErr)
+}
+
+// Stats returns the counters of every endpoint in configuration order.
+func (p *Pool) Stats() []EndpointStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stats := make([]EndpointStats, len(p.endpoints))
+	for i, e := range p.endpoints {
+		stats[i] = EndpointStats{
+			URL:     e.url,
+			Healthy: e.healthy,
+			Latency: e.latency,
+			Calls:   atomic.LoadUint64(&e.calls),
+			Errors:  atomic.LoadUint64(&e.errors),
+		}
+	}
+	return stats
+}
+
+func (p *Pool) ChainID(ctx context.Context) (id *big.Int, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { id, err = c.ChainID(ctx); return err })
+	return
+}
+
+func (p *Pool) HeaderByNumber(ctx context.Context, number *big.Int) (h *types.Header, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { h, err = c.HeaderByNumber(ctx, number); return err })
+	return
+}
+
+func (p *Pool) BlockByNumber(ctx context.Context, number *big.Int) (b *types.Block, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { b, err = c.BlockByNumber(ctx, number); return err })
+	return
+}
+
+func (p *Pool) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (b *big.Int, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { b, err = c.BalanceAt(ctx, account, blockNumber); return err })
+	return
+}
+
+func (p *Pool) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (n uint64, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { n, err = c.NonceAt(ctx, account, blockNumber); return err })
+	return
+}
+
+func (p *Pool) PendingNonceAt(ctx context.Context, account common.Address) (n uint64, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { n, err = c.PendingNonceAt(ctx, account); return err })
+	return
+}
+
+func (p *Pool) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) (code []byte, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { code, err = c.CodeAt(ctx, account, blockNumber); return err })
+	return
+}
+
+func (p *Pool) PendingCodeAt(ctx context.Context, account common.Address) (code []byte, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { code, err = c.PendingCodeAt(ctx, account); return err })
+	return
+}
+
+func (p *Pool) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) (out []byte, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { out, err = c.CallContract(ctx, msg, blockNumber); return err })
+	return
+}
+
+func (p *Pool) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) (out []byte, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { out, err = c.PendingCallContract(ctx, msg); return err })
+	return
+}
+
+func (p *Pool) SuggestGasPrice(ctx context.Context) (price *big.Int, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { price, err = c.SuggestGasPrice(ctx); return err })
+	return
+}
+
+func (p *Pool) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (gas uint64, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { gas, err = c.EstimateGas(ctx, msg); return err })
+	return
+}
+
+// SendTransaction broadcasts tx. If an endpoint failed after the transaction
+// reached the network, the next one reports it as known, which counts as
+// success.
+func (p *Pool) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	tried := false
+	return p.do(ctx, func(c *ethclient.Client) error {
+		err := c.SendTransaction(ctx, tx)
+		if err != nil && tried && isKnownTx(err) {
+			return nil
+		}
+		tried = true
+		return err
+	})
+}
+
+func isKnownTx(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "already known") || strings.Contains(msg, "known transaction")
+}
+
+func (p *Pool) TransactionReceipt(ctx context.Context, hash common.Hash) (r *types.Receipt, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { r, err = c.TransactionReceipt(ctx, hash); return err })
+	return
+}
+
+func (p *Pool) TransactionByHash(ctx context.Context, hash common.Hash) (tx *types.Transaction, isPending bool, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { tx, isPending, err = c.TransactionByHash(ctx, hash); return err })
+	return
+}
+
+func (p *Pool) FilterLogs(ctx context.Context, q ethereum.FilterQuery) (logs []types.Log, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { logs, err = c.FilterLogs(ctx, q); return err })
+	return
+}
+
+func (p *Pool) SubscribeFilterLogs(ctx context.Context, q ethereum.FilterQuery, ch chan<- types.Log) (sub ethereum.Subscription, err error) {
+	err = p.do(ctx, func(c *ethclient.Client) error { sub, err = c.SubscribeFilterLogs(ctx, q, ch); return err })
+	return
+}
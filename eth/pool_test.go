@@ -0,0 +1,100 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"testing"
+	"time"
+)
+
+// rpcError is an error answered by a node.
+type rpcError struct{}
+
+func (rpcError) Error() string  { return "execution reverted" }
+func (rpcError) ErrorCode() int { return 3 }
+
+func TestRetryable(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{context.Background(), nil, false},
+		{context.Background(), ethereum.NotFound, false},
+		{context.Background(), rpcError{}, false},
+		{context.Background(), fmt.Errorf("call failed, %w", rpcError{}), false},
+		{context.Background(), errors.New("connection refused"), true},
+		{cancelled, errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		if got := retryable(tt.ctx, tt.err); got != tt.want {
+			t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// testPool returns a pool of n endpoints that are not due for a health check,
+// so calls go to them in order.
+func testPool(n int) *Pool {
+	p := &Pool{checked: time.Now()}
+	for i := 0; i < n; i++ {
+		e := &endpoint{url: fmt.Sprintf("http://node%d", i), client: new(ethclient.Client), healthy: true}
+		p.endpoints = append(p.endpoints, e)
+		p.order = append(p.order, e)
+	}
+	return p
+}
+
+func TestPoolDo(t *testing.T) {
+	down := errors.New("connection refused")
+	tests := []struct {
+		name   string
+		errs   []error // returned by the endpoints in order
+		want   error
+		calls  []uint64
+		errors []uint64
+	}{
+		{"first answers", []error{nil, nil}, nil, []uint64{1, 0}, []uint64{0, 0}},
+		{"fails over", []error{down, nil}, nil, []uint64{1, 1}, []uint64{1, 0}},
+		{"node error is final", []error{rpcError{}, nil}, rpcError{}, []uint64{1, 0}, []uint64{0, 0}},
+		{"not found is final", []error{ethereum.NotFound, nil}, ethereum.NotFound, []uint64{1, 0}, []uint64{0, 0}},
+		{"all down", []error{down, down}, down, []uint64{1, 1}, []uint64{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPool(len(tt.errs))
+			err := p.do(context.Background(), func(c *ethclient.Client) error {
+				for i, e := range p.endpoints {
+					if e.client == c {
+						return tt.errs[i]
+					}
+				}
+				t.Fatal("call on an unknown client")
+				return nil
+			})
+			if err != tt.want {
+				t.Fatalf("do returned %v, want %v", err, tt.want)
+			}
+			for i, s := range p.Stats() {
+				if s.Calls != tt.calls[i] || s.Errors != tt.errors[i] {
+					t.Errorf("endpoint %d has %d calls %d errors, want %d and %d", i, s.Calls, s.Errors, tt.calls[i], tt.errors[i])
+				}
+				if s.Healthy != (tt.errors[i] == 0) {
+					t.Errorf("endpoint %d healthy %v after %d errors", i, s.Healthy, s.Errors)
+				}
+			}
+		})
+	}
+
+	if err := (&Pool{checked: time.Now(), chainId: 56}).do(context.Background(), func(*ethclient.Client) error {
+		t.Fatal("call without an endpoint")
+		return nil
+	}); err == nil {
+		t.Error("do succeeded without an endpoint")
+	}
+}
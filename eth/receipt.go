@@ -0,0 +1,168 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhaozilong88/cashout/eth/gps"
+	"time"
+)
+
+var (
+	chequeCashedTopic = crypto.Keccak256Hash([]byte("ChequeCashed(address,address,uint256,uint256)"))
+
+	ErrReverted       = errors.New("transaction reverted")
+	ErrNoChequeCashed = errors.New("no ChequeCashed event in receipt")
+)
+
+type TxState int
+
+const (
+	// TxUnknown means the node knows nothing about the transaction, it was
+	// never broadcast or has been dropped from the mempool.
+	TxUnknown TxState = iota
+	TxPending
+	TxMined
+)
+
+// TxState looks up a transaction by hash. For mined transactions the receipt
+// is returned as well.
+func (c *Contract) TxState(ctx context.Context, hash common.Hash) (TxState, *types.Receipt, error) {
+	receipt, err := c.client.TransactionReceipt(ctx, hash)
+	if err == nil {
+		return TxMined, receipt, nil
+	}
+	if err != ethereum.NotFound {
+		return TxUnknown, nil, err
+	}
+	_, isPending, err := c.client.TransactionByHash(ctx, hash)
+	if err == ethereum.NotFound {
+		return TxUnknown, nil, nil
+	}
+	if err != nil {
+		return TxUnknown, nil, err
+	}
+	if isPending {
+		return TxPending, nil, nil
+	}
+	// mined between the two calls, look the receipt up once more and report
+	// it pending if the node has not indexed it yet, callers poll again
+	receipt, err = c.client.TransactionReceipt(ctx, hash)
+	if err == nil {
+		return TxMined, receipt, nil
+	}
+	if err != ethereum.NotFound {
+		return TxUnknown, nil, err
+	}
+	return TxPending, nil, nil
+}
+
+// CashoutResult is a mined cashCheque transaction and the event it emitted.
+type CashoutResult struct {
+	Receipt *types.Receipt
+	Event   *gps.GPSTokenChequeCashed
+}
+
+// WaitCashout waits until one of txs, a cashout and the transactions that
+// replaced it, is mined with the configured number of confirmations and
+// decodes its ChequeCashed event. It fails with ErrReverted when the receipt
+// status is not successful and with ErrNoChequeCashed when the contract paid
+// nothing out.
+func (c *Contract) WaitCashout(ctx context.Context, txs ...*types.Transaction) (*CashoutResult, error) {
+	receipt, err := c.Wait(ctx, txs...)
+	if receipt == nil {
+		return nil, err
+	}
+	result := &CashoutResult{Receipt: receipt}
+	if err != nil {
+		return result, err
+	}
+	for _, l := range receipt.Logs {
+		if l.Address != c.address || len(l.Topics) == 0 || l.Topics[0] != chequeCashedTopic {
+			continue
+		}
+		event, err := c.token.ParseChequeCashed(*l)
+		if err != nil {
+			log.Errorf("failed to parse ChequeCashed, %v", err)
+			return result, err
+		}
+		result.Event = event
+		return result, nil
+	}
+	return result, ErrNoChequeCashed
+}
+
+// Wait waits until one of txs is mined with the configured number of
+// confirmations. It fails with ErrReverted, along with the receipt, when the
+// receipt status is not successful.
+func (c *Contract) Wait(ctx context.Context, txs ...*types.Transaction) (*types.Receipt, error) {
+	receipt, err := c.waitConfirmed(ctx, txs)
+	if err != nil {
+		log.Errorf("failed to wait for %s, %v", txs[len(txs)-1].Hash().Hex(), err)
+		return nil, err
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return receipt, ErrReverted
+	}
+	return receipt, nil
+}
+
+// waitConfirmed waits for the receipt of any of txs and then for the chain to
+// grow past the confirmation depth. If the transaction moved to another block
+// in the meantime the wait starts over.
+func (c *Contract) waitConfirmed(ctx context.Context, txs []*types.Transaction) (*types.Receipt, error) {
+	if c.conf.ReceiptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.conf.ReceiptTimeout)
+		defer cancel()
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		receipt, err := c.waitMined(ctx, txs, ticker)
+		if err != nil {
+			return nil, err
+		}
+		if c.conf.Confirmations <= 1 {
+			return receipt, nil
+		}
+		target := receipt.BlockNumber.Uint64() + c.conf.Confirmations - 1
+		for {
+			head, err := c.client.HeaderByNumber(ctx, nil)
+			if err == nil && head.Number.Uint64() >= target {
+				break
+			}
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-ticker.C:
+			}
+		}
+		latest, err := c.client.TransactionReceipt(ctx, receipt.TxHash)
+		if err == nil && latest.BlockHash == receipt.BlockHash {
+			return latest, nil
+		}
+	}
+}
+
+// waitMined polls for the first receipt of any of txs, like bind.WaitMined
+// does for a single transaction.
+func (c *Contract) waitMined(ctx context.Context, txs []*types.Transaction, ticker *time.Ticker) (*types.Receipt, error) {
+	for {
+		for _, tx := range txs {
+			receipt, err := c.client.TransactionReceipt(ctx, tx.Hash())
+			if err == nil {
+				return receipt, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
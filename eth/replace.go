@@ -0,0 +1,97 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"math/big"
+)
+
+// minBumpPercent is the gas price increase nodes require before they accept a
+// replacement for a pending transaction.
+const minBumpPercent = 10
+
+var ErrGasPriceCap = errors.New("bumped gas price exceeds max_gas_price")
+
+// Nonces returns the nonce after the latest mined transaction of addr and
+// its pending nonce. A pending nonce ahead of the mined one means the address
+// has transactions waiting in the mempool.
+func (c *Contract) Nonces(ctx context.Context, addr common.Address) (mined, pending uint64, err error) {
+	mined, err = c.client.NonceAt(ctx, addr, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	pending, err = c.client.PendingNonceAt(ctx, addr)
+	if err != nil {
+		return 0, 0, err
+	}
+	return mined, pending, nil
+}
+
+// PendingTransaction returns tx if the node still has it in its mempool.
+func (c *Contract) PendingTransaction(ctx context.Context, hash common.Hash) (*types.Transaction, error) {
+	tx, isPending, err := c.client.TransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if !isPending {
+		return nil, errors.New("transaction is already mined")
+	}
+	return tx, nil
+}
+
+// BumpGasPrice raises price by percent, at least by the minimum nodes accept
+// for replacements and at least to the current gas price. It fails with
+// ErrGasPriceCap if that exceeds MaxGasPrice.
+func (c *Contract) BumpGasPrice(ctx context.Context, price *big.Int, percent int) (*big.Int, error) {
+	if percent < minBumpPercent {
+		percent = minBumpPercent
+	}
+	bumped := new(big.Int).Mul(price, big.NewInt(int64(100+percent)))
+	bumped.Add(bumped, big.NewInt(99))
+	bumped.Div(bumped, big.NewInt(100))
+
+	current, err := c.gasPrice(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if current.Cmp(bumped) > 0 {
+		bumped = current
+	}
+	if c.conf.MaxGasPrice > 0 && bumped.Cmp(new(big.Int).Mul(big.NewInt(c.conf.MaxGasPrice), gwei)) > 0 {
+		return nil, ErrGasPriceCap
+	}
+	return bumped, nil
+}
+
+// Replace signs a copy of the pending tx with gasPrice and broadcasts it, so
+// that it replaces tx in the mempool.
+func (c *Contract) Replace(ctx context.Context, signer Signer, tx *types.Transaction, gasPrice *big.Int, beforeSend BeforeSend) (*types.Transaction, error) {
+	replacement := types.NewTransaction(tx.Nonce(), *tx.To(), tx.Value(), tx.Gas(), gasPrice, tx.Data())
+	return c.signAndSend(ctx, signer, replacement, beforeSend)
+}
+
+// Cancel replaces whatever transaction of signer is pending at nonce with a
+// zero value transfer to itself.
+func (c *Contract) Cancel(ctx context.Context, signer Signer, nonce uint64, gasPrice *big.Int, beforeSend BeforeSend) (*types.Transaction, error) {
+	self := signer.Address()
+	return c.signAndSend(ctx, signer, types.NewTransaction(nonce, self, new(big.Int), transferGas, gasPrice, nil), beforeSend)
+}
+
+func (c *Contract) signAndSend(ctx context.Context, signer Signer, tx *types.Transaction, beforeSend BeforeSend) (*types.Transaction, error) {
+	signed, err := signer.SignTx(tx, c.chainId)
+	if err != nil {
+		return nil, err
+	}
+	if beforeSend != nil {
+		if err := beforeSend(signed); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.client.SendTransaction(ctx, signed); err != nil {
+		log.Errorf("failed to send %s, %v", signed.Hash().Hex(), err)
+		return nil, err
+	}
+	return signed, nil
+}
@@ -0,0 +1,50 @@
+package eth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"math/big"
+)
+
+// Signer signs the transactions of one account. KeySigner keeps the key in
+// memory; other implementations can sign remotely or on a hardware wallet.
+type Signer interface {
+	Address() common.Address
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+}
+
+// KeySigner signs with a private key.
+type KeySigner struct {
+	key     *ecdsa.PrivateKey
+	address common.Address
+}
+
+func NewKeySigner(key *ecdsa.PrivateKey) *KeySigner {
+	return &KeySigner{key: key, address: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+func (s *KeySigner) Address() common.Address {
+	return s.address
+}
+
+func (s *KeySigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), s.key)
+}
+
+// transactOpts returns transact options that send from signer.
+func (c *Contract) transactOpts(ctx context.Context, signer Signer) *bind.TransactOpts {
+	return &bind.TransactOpts{
+		From:    signer.Address(),
+		Context: ctx,
+		Signer: func(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+			if from != signer.Address() {
+				return nil, bind.ErrNotAuthorized
+			}
+			return signer.SignTx(tx, c.chainId)
+		},
+	}
+}
@@ -0,0 +1,85 @@
+package eth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/zhaozilong88/cashout/eth/gps"
+	"math/big"
+	"strings"
+)
+
+var tokenABI = mustParseABI(gps.GPSTokenABI)
+
+func mustParseABI(s string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(s))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
+// RevertError is returned when a simulated call reverts.
+type RevertError struct {
+	Reason string
+}
+
+func (e *RevertError) Error() string {
+	if e.Reason == "" {
+		return "execution reverted"
+	}
+	return "execution reverted: " + e.Reason
+}
+
+// SimulateCashout executes the cashCheque call Cashout would send from
+// beneficiary as an eth_call against the pending state, with the same
+// calldata, gas price and gas limit, so a cashout that would run out of gas
+// fails here too. A nil error means the transaction is expected to succeed; a
+// revert is reported as *RevertError.
+func (c *Contract) SimulateCashout(ctx context.Context, beneficiary common.Address, cumulativePayout *big.Int, issuerSig []byte) error {
+	data, err := tokenABI.Pack("cashCheque", cumulativePayout, issuerSig)
+	if err != nil {
+		return err
+	}
+	gasPrice, err := c.gasPrice(ctx)
+	if err != nil {
+		return err
+	}
+	gasLimit, err := c.gasLimit(ctx, beneficiary, gasPrice, "cashCheque", cumulativePayout, issuerSig)
+	if err != nil {
+		return err
+	}
+	msg := ethereum.CallMsg{
+		From:     beneficiary,
+		To:       &c.address,
+		Gas:      gasLimit,
+		GasPrice: gasPrice,
+		Data:     data,
+	}
+	if _, err := c.client.PendingCallContract(ctx, msg); err != nil {
+		return revertError(err)
+	}
+	return nil
+}
+
+// revertError extracts the Error(string) reason from an eth_call error, if
+// the node returned the revert data.
+func revertError(err error) error {
+	var dataErr rpc.DataError
+	if !errors.As(err, &dataErr) {
+		return err
+	}
+	hexData, ok := dataErr.ErrorData().(string)
+	if !ok {
+		return err
+	}
+	reason, uerr := abi.UnpackRevert(common.FromHex(hexData))
+	if uerr != nil {
+		return fmt.Errorf("%w (%s)", err, hexData)
+	}
+	return &RevertError{Reason: reason}
+}
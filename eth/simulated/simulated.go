@@ -0,0 +1,91 @@
+// Package simulated runs the GPSToken contract on go-ethereum's simulated
+// backend, so the cashout flow can be exercised end to end without a network.
+package simulated
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhaozilong88/cashout/eth"
+	"github.com/zhaozilong88/cashout/eth/gps"
+	"math/big"
+)
+
+// ChainID is the chain ID the simulated backend always uses.
+const ChainID = 1337
+
+const blockGasLimit = 10000000
+
+// Chain is a simulated chain with a GPSToken deployed by an issuer key.
+// Cheques for it can be served with cheque.Server.
+type Chain struct {
+	backend *backends.SimulatedBackend
+	address common.Address
+}
+
+// New starts a chain on which issuer and every funded address hold balance
+// wei, and deploys GPSToken from issuer.
+func New(issuer *ecdsa.PrivateKey, balance *big.Int, funded ...common.Address) (*Chain, error) {
+	alloc := core.GenesisAlloc{crypto.PubkeyToAddress(issuer.PublicKey): {Balance: balance}}
+	for _, addr := range funded {
+		alloc[addr] = core.GenesisAccount{Balance: balance}
+	}
+	backend := backends.NewSimulatedBackend(alloc, blockGasLimit)
+	auth, err := bind.NewKeyedTransactorWithChainID(issuer, big.NewInt(ChainID))
+	if err != nil {
+		backend.Close()
+		return nil, err
+	}
+	address, _, _, err := gps.DeployGPSToken(auth, backend)
+	if err != nil {
+		backend.Close()
+		return nil, fmt.Errorf("failed to deploy GPSToken, %v", err)
+	}
+	backend.Commit()
+	return &Chain{backend: backend, address: address}, nil
+}
+
+// Address returns the address of the GPSToken contract.
+func (c *Chain) Address() common.Address {
+	return c.address
+}
+
+// Backend returns the chain as an eth.Backend that mines a block for every
+// transaction sent, so waiting for receipts needs no separate miner.
+func (c *Chain) Backend() eth.Backend {
+	return autoMine{c.backend}
+}
+
+// Contract connects an eth.Contract to the chain. The network, chain ID and
+// contract address of conf are replaced by those of c.
+func (c *Chain) Contract(conf eth.Config) (*eth.Contract, error) {
+	conf.Network = ""
+	conf.Endpoints = nil
+	conf.ChainID = ChainID
+	conf.ContractAddress = c.address.Hex()
+	return eth.NewContractWithBackend(conf, c.Backend(), eth.StaticChainID(ChainID))
+}
+
+// Close stops the chain.
+func (c *Chain) Close() error {
+	return c.backend.Close()
+}
+
+// autoMine commits a block right after every transaction.
+type autoMine struct {
+	*backends.SimulatedBackend
+}
+
+func (b autoMine) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	if err := b.SimulatedBackend.SendTransaction(ctx, tx); err != nil {
+		return err
+	}
+	b.Commit()
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhaozilong88/cashout/cheque"
+	"math/big"
+	"net/http"
+)
+
+// runFakeAPI serves cheques like api.gpfs.xyz, signed with the key of
+// -fake_api_issuer_key for -chain_id, until interrupted. Point -cheque_api of
+// another cashout at it to test against a simulated or local dev chain.
+func runFakeAPI(ctx context.Context, a *app) error {
+	c := a.cfg.FakeAPI
+	keys := readKeys(c.IssuerKeyFile, a.messages())
+	if len(keys) == 0 {
+		return fmt.Errorf("no issuer key in %s", c.IssuerKeyFile)
+	}
+	if a.cfg.Eth.ChainID == 0 {
+		return errors.New("fake-api needs -chain_id, cheques are only valid on one chain")
+	}
+	server := cheque.NewServer(keys[0], new(big.Int).SetUint64(a.cfg.Eth.ChainID))
+	if c.Cheques != "" {
+		if err := server.LoadFile(c.Cheques); err != nil {
+			return fmt.Errorf("failed to read cheques, %v", err)
+		}
+	}
+
+	srv := &http.Server{Addr: c.Listen, Handler: server}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	fmt.Fprintf(a.messages(), "serving cheques of issuer %s for chain %d on http://%s/v1/cheque\n",
+		crypto.PubkeyToAddress(keys[0].PublicKey).Hex(), a.cfg.Eth.ChainID, c.Listen)
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhaozilong88/cashout/cashout"
+	"github.com/zhaozilong88/cashout/eth"
+	"io"
+	"math/big"
+)
+
+// etherDecimals is the number of decimals of BNB amounts in wei.
+const etherDecimals = 18
+
+var ether = new(big.Int).Exp(big.NewInt(10), big.NewInt(etherDecimals), nil)
+
+// fundPolicy tops up the BNB balance of node addresses from a funder key so
+// they can pay for their cashouts.
+type fundPolicy struct {
+	funder *ecdsa.PrivateKey
+	// cashouts is how many cashouts the balance of every address should cover
+	cashouts int64
+	// maxPerAddress and maxTotal cap the wei sent to one address and in total
+	maxPerAddress *big.Int
+	maxTotal      *big.Int
+	// dryRun only prints the planned transfers
+	dryRun bool
+}
+
+func newFundPolicy(c fundConfig, dryRun bool, msgs io.Writer) (*fundPolicy, error) {
+	keys := readKeys(c.KeyFile, msgs)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no funder key in %s", c.KeyFile)
+	}
+	perAddress, err := cashout.ParseAmount(c.MaxPerAddress, etherDecimals)
+	if err != nil {
+		return nil, err
+	}
+	total, err := cashout.ParseAmount(c.MaxTotal, etherDecimals)
+	if err != nil {
+		return nil, err
+	}
+	return &fundPolicy{
+		funder:        keys[0],
+		cashouts:      c.Cashouts,
+		maxPerAddress: perAddress,
+		maxTotal:      total,
+		dryRun:        dryRun,
+	}, nil
+}
+
+func formatEther(wei *big.Int) string {
+	return new(big.Rat).SetFrac(wei, ether).FloatString(6)
+}
+
+// fundKeys sends every key whose BNB balance does not cover p.cashouts
+// cashouts the difference, capped per address and in total. Transfers are
+// sent one after another from the funder and waited for at the end.
+func fundKeys(ctx context.Context, contract *eth.Contract, p *fundPolicy, keys []*ecdsa.PrivateKey, out io.Writer) error {
+	funder := crypto.PubkeyToAddress(p.funder.PublicKey)
+	cost, err := contract.CashoutCost(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to estimate cashout cost, %v", err)
+	}
+	fee, err := contract.TransferCost(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to estimate transfer cost, %v", err)
+	}
+	available, err := contract.NativeBalance(ctx, funder)
+	if err != nil {
+		return fmt.Errorf("failed to get balance of funder %s, %v", funder.String(), err)
+	}
+	target := new(big.Int).Mul(cost, big.NewInt(p.cashouts))
+	fmt.Fprintf(out, "funder %s balance %s, cashout cost %s, target %s\n", funder.String(), formatEther(available), formatEther(cost), formatEther(target))
+
+	total := new(big.Int)
+	var sent []*types.Transaction
+	var failed int
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			break
+		}
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		if addr == funder {
+			continue
+		}
+		balance, err := contract.NativeBalance(ctx, addr)
+		if err != nil {
+			fmt.Fprintf(out, "failed to get balance of %s, %v\n", addr.String(), err)
+			continue
+		}
+		if balance.Cmp(target) >= 0 {
+			continue
+		}
+		amount := new(big.Int).Sub(target, balance)
+		if p.maxPerAddress.Sign() > 0 && amount.Cmp(p.maxPerAddress) > 0 {
+			amount.Set(p.maxPerAddress)
+		}
+		if p.maxTotal.Sign() > 0 && new(big.Int).Add(total, amount).Cmp(p.maxTotal) > 0 {
+			fmt.Fprintf(out, "skip %s, sending %s would exceed the total cap of %s\n", addr.String(), formatEther(amount), formatEther(p.maxTotal))
+			continue
+		}
+		if new(big.Int).Add(amount, fee).Cmp(available) > 0 {
+			fmt.Fprintf(out, "skip %s, funder has %s left\n", addr.String(), formatEther(available))
+			continue
+		}
+
+		if p.dryRun {
+			fmt.Fprintf(out, "%s balance %s, would send %s\n", addr.String(), formatEther(balance), formatEther(amount))
+		} else {
+			tx, err := contract.SendValue(ctx, eth.NewKeySigner(p.funder), addr, amount)
+			if err != nil {
+				fmt.Fprintf(out, "failed to fund %s, %v\n", addr.String(), err)
+				failed++
+				continue
+			}
+			fmt.Fprintf(out, "%s balance %s, sent %s in %s\n", addr.String(), formatEther(balance), formatEther(amount), tx.Hash().Hex())
+			sent = append(sent, tx)
+		}
+		total.Add(total, amount)
+		available.Sub(available, new(big.Int).Add(amount, fee))
+	}
+
+	for _, tx := range sent {
+		if _, err := contract.Wait(ctx, tx); err != nil {
+			fmt.Fprintf(out, "funding %s failed, %v\n", tx.Hash().Hex(), err)
+			failed++
+		}
+	}
+	verb := "sent"
+	if p.dryRun {
+		verb = "would send"
+	}
+	fmt.Fprintf(out, "%s %s in total\n", verb, formatEther(total))
+	if failed > 0 {
+		return fmt.Errorf("%d funding transactions failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	mnemonicEnv           = "CASHOUT_MNEMONIC"
+	mnemonicPassphraseEnv = "CASHOUT_MNEMONIC_PASSPHRASE"
+)
+
+var errInvalidChildKey = errors.New("derived key is invalid")
+
+// readMnemonic returns the mnemonic from mnemonicFile, falling back to the
+// environment. An empty result means no mnemonic was configured.
+func readMnemonic(mnemonicFile string) (string, error) {
+	if mnemonicFile != "" {
+		data, err := ioutil.ReadFile(mnemonicFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(strings.Fields(string(data)), " "), nil
+	}
+	return strings.Join(strings.Fields(os.Getenv(mnemonicEnv)), " "), nil
+}
+
+// deriveKeys derives count keys from a BIP-39 mnemonic, substituting the
+// indexes start..start+count-1 for {i} in the BIP-32 path template.
+func deriveKeys(mnemonic, passphrase, pathTemplate string, start, count int) ([]*ecdsa.PrivateKey, error) {
+	if !strings.Contains(pathTemplate, "{i}") {
+		return nil, fmt.Errorf("derivation path %q has no {i} placeholder", pathTemplate)
+	}
+	seed, err := bip39.NewSeedWithErrorChecking(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, chainCode, err := masterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*ecdsa.PrivateKey
+	for i := start; i < start+count; i++ {
+		path, err := accounts.ParseDerivationPath(strings.Replace(pathTemplate, "{i}", strconv.Itoa(i), -1))
+		if err != nil {
+			return nil, err
+		}
+		key, code := master, chainCode
+		for _, index := range path {
+			key, code, err = childKey(key, code, index)
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %v", i, err)
+			}
+		}
+		list = append(list, key)
+	}
+	return list, nil
+}
+
+// masterKey computes the BIP-32 master key and chain code for seed.
+func masterKey(seed []byte) (*ecdsa.PrivateKey, []byte, error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, err := crypto.ToECDSA(sum[:32])
+	if err != nil {
+		return nil, nil, errInvalidChildKey
+	}
+	return key, sum[32:], nil
+}
+
+// childKey computes the BIP-32 private child key at index. Indexes at or above
+// accounts.DerivationPath's hardened offset (0x80000000) are hardened.
+func childKey(parent *ecdsa.PrivateKey, chainCode []byte, index uint32) (*ecdsa.PrivateKey, []byte, error) {
+	var data []byte
+	if index >= 0x80000000 {
+		data = append([]byte{0}, math.PaddedBigBytes(parent.D, 32)...)
+	} else {
+		data = crypto.CompressPubkey(&parent.PublicKey)
+	}
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], index)
+	data = append(data, buf[:]...)
+
+	mac := hmac.New(sha512.New, chainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, nil, errInvalidChildKey
+	}
+	d := il.Add(il, parent.D)
+	d.Mod(d, n)
+	if d.Sign() == 0 {
+		return nil, nil, errInvalidChildKey
+	}
+	key, err := crypto.ToECDSA(math.PaddedBigBytes(d, 32))
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, sum[32:], nil
+}
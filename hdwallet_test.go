@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/ethereum/go-ethereum/crypto"
+	"testing"
+)
+
+func TestDeriveKeys(t *testing.T) {
+	// the standard BIP-39 test mnemonic and its well known addresses
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	tests := []struct {
+		path  string
+		start int
+		want  []string
+	}{
+		{path: "m/44'/60'/0'/0/{i}", start: 0, want: []string{
+			"0x9858EfFD232B4033E47d90003D41EC34EcaEda94",
+			"0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0",
+		}},
+		{path: "m/44'/60'/0'/0/{i}", start: 1, want: []string{
+			"0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0",
+		}},
+	}
+	for _, tt := range tests {
+		keys, err := deriveKeys(mnemonic, "", tt.path, tt.start, len(tt.want))
+		if err != nil {
+			t.Fatalf("deriveKeys(%s, %d): %v", tt.path, tt.start, err)
+		}
+		if len(keys) != len(tt.want) {
+			t.Fatalf("deriveKeys(%s, %d) returned %d keys, want %d", tt.path, tt.start, len(keys), len(tt.want))
+		}
+		for i, key := range keys {
+			if got := crypto.PubkeyToAddress(key.PublicKey).Hex(); got != tt.want[i] {
+				t.Errorf("deriveKeys(%s, %d)[%d] = %s, want %s", tt.path, tt.start, i, got, tt.want[i])
+			}
+		}
+	}
+
+	if _, err := deriveKeys("abandon abandon about", "", "m/44'/60'/0'/0/{i}", 0, 1); err == nil {
+		t.Error("deriveKeys accepted an invalid mnemonic")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/zhaozilong88/cashout/cashout"
+	"strconv"
+)
+
+// runBalance prints the GPS token and BNB balance of every key.
+func runBalance(ctx context.Context, a *app) error {
+	t := newTable(a.cfg.Output, a.out, false, "address", "gps", "bnb")
+	rows := make([][]byte, len(a.keys))
+	cashout.ForEach(ctx, a.cfg.Workers, len(a.keys), func(i int) {
+		addr := crypto.PubkeyToAddress(a.keys[i].PublicKey)
+		tokens, err := a.contract.BalanceOf(ctx, addr)
+		if err != nil {
+			fmt.Fprintf(a.messages(), "failed to get balance of %s, %v\n", addr.String(), err)
+			return
+		}
+		native, err := a.contract.NativeBalance(ctx, addr)
+		if err != nil {
+			fmt.Fprintf(a.messages(), "failed to get BNB balance of %s, %v\n", addr.String(), err)
+			return
+		}
+		rows[i] = t.line(addr.String(), a.printer.decimal(tokens), weiDecimal(native))
+	}, func(i int) {
+		t.out.Write(rows[i])
+	})
+	t.flush()
+	return nil
+}
+
+// runInfo prints the metadata of the GPSToken contract.
+func runInfo(ctx context.Context, a *app) error {
+	info, err := a.contract.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read contract, %v", err)
+	}
+	decimals := int(info.Decimals)
+	t := newTable(a.cfg.Output, a.out, true, "contract", "chain_id", "name", "symbol", "decimals", "total_supply", "max_supply", "issuer")
+	t.row(info.Address.String(), info.ChainID.String(), info.Name, info.Symbol, strconv.Itoa(decimals),
+		cashout.FormatAmount(info.TotalSupply, decimals), cashout.FormatAmount(info.MaxSupply, decimals), info.Issuer.String())
+	t.flush()
+	return nil
+}
+
+// runKeys lists the address of every key with the source it was loaded
+// from, then reports key file lines that were skipped and duplicate keys.
+func runKeys(_ context.Context, a *app) error {
+	msgs := messageWriter(a.cfg.Output, a.out)
+	t := newTable(a.cfg.Output, a.out, false, "index", "address", "source")
+	seen := map[string]int{}
+	var duplicates []string
+	for i, k := range a.named {
+		addr := crypto.PubkeyToAddress(k.key.PublicKey).String()
+		if first, ok := seen[addr]; ok {
+			duplicates = append(duplicates, fmt.Sprintf("%s: key %d is a duplicate of key %d", addr, i, first))
+		} else {
+			seen[addr] = i
+		}
+		t.row(strconv.Itoa(i), addr, k.source)
+	}
+	t.flush()
+
+	var problems []string
+	if fileExists(a.cfg.Keys.KeyFile) {
+		var err error
+		problems, err = checkKeyFile(a.cfg.Keys.KeyFile)
+		if err != nil {
+			return err
+		}
+	}
+	problems = append(problems, duplicates...)
+	for _, p := range problems {
+		fmt.Fprintln(msgs, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problems with the keys", len(problems))
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/ethereum/go-ethereum/crypto"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// namedKey is a private key and the source it was loaded from.
+type namedKey struct {
+	key    *ecdsa.PrivateKey
+	source string
+}
+
+// loadNamedKeys collects the private keys from every configured key source.
+// The plain key file is only required when no other source is configured.
+// Keys that cannot be read are reported to msgs and skipped.
+func loadNamedKeys(c keysConfig, msgs io.Writer) ([]namedKey, error) {
+	mnemonic, err := readMnemonic(c.MnemonicFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mnemonic, %v", err)
+	}
+
+	var keys []namedKey
+	add := func(list []*ecdsa.PrivateKey, source func(i int) string) {
+		for i, k := range list {
+			keys = append(keys, namedKey{key: k, source: source(i)})
+		}
+	}
+	if (c.Keystore == "" && mnemonic == "") || fileExists(c.KeyFile) {
+		add(readKeys(c.KeyFile, msgs), func(int) string { return c.KeyFile })
+	}
+	if c.Keystore != "" {
+		passphrase, err := keystorePassphrase(c.PasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keystore passphrase, %v", err)
+		}
+		add(readKeystore(c.Keystore, passphrase, msgs), func(int) string { return c.Keystore })
+	}
+	if mnemonic != "" {
+		derived, err := deriveKeys(mnemonic, os.Getenv(mnemonicPassphraseEnv), c.HDPath, c.HDStart, c.HDCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive keys, %v", err)
+		}
+		add(derived, func(i int) string {
+			return strings.Replace(c.HDPath, "{i}", strconv.Itoa(c.HDStart+i), -1)
+		})
+	}
+	return keys, nil
+}
+
+func readKeys(filename string, msgs io.Writer) []*ecdsa.PrivateKey {
+	var list []*ecdsa.PrivateKey
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(msgs, "failed to read file, %v\n", err)
+		return list
+	}
+	ss := strings.Split(string(data), "\n")
+	for _, str := range ss {
+		s := strings.TrimSpace(str)
+		if len(s) == len("77dd33ed201813038b5c9a33b9eb0d4a07c3b83bd88e709e40228b762feedecd") {
+			prvKey, err := crypto.HexToECDSA(s)
+			if err != nil {
+				fmt.Fprintf(msgs, "key error: %v\n", s)
+				continue
+			}
+			list = append(list, prvKey)
+		}
+	}
+	return list
+}
+
+// checkKeyFile returns a problem for every line of the key file readKeys
+// would skip or fail on.
+func checkKeyFile(filename string) ([]string, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var problems []string
+	for i, str := range strings.Split(string(data), "\n") {
+		s := strings.TrimSpace(str)
+		switch {
+		case s == "":
+		case len(s) != 64:
+			problems = append(problems, fmt.Sprintf("%s:%d: not a 64 character hex key", filename, i+1))
+		default:
+			if _, err := crypto.HexToECDSA(s); err != nil {
+				problems = append(problems, fmt.Sprintf("%s:%d: %v", filename, i+1, err))
+			}
+		}
+	}
+	return problems, nil
+}
+
+func fileExists(filename string) bool {
+	fi, err := os.Stat(filename)
+	return err == nil && !fi.IsDir()
+}
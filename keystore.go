@@ -0,0 +1,76 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"golang.org/x/crypto/ssh/terminal"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// passwordEnv is the environment variable checked for the keystore passphrase
+// when no password file is given.
+const passwordEnv = "CASHOUT_KEYSTORE_PASSWORD"
+
+// readKeystore decrypts every V3 JSON keystore file in dir with passphrase.
+// Files that are not keystores or fail to decrypt are reported to msgs and
+// skipped.
+func readKeystore(dir string, passphrase string, msgs io.Writer) []*ecdsa.PrivateKey {
+	var list []*ecdsa.PrivateKey
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		fmt.Fprintf(msgs, "failed to read keystore dir, %v\n", err)
+		return list
+	}
+	for _, fi := range files {
+		name := fi.Name()
+		// skip editor backups and hidden files, like geth does
+		if fi.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, "~") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			fmt.Fprintf(msgs, "failed to read keystore file %s, %v\n", name, err)
+			continue
+		}
+		key, err := keystore.DecryptKey(data, passphrase)
+		if err != nil {
+			fmt.Fprintf(msgs, "failed to decrypt keystore file %s, %v\n", name, err)
+			continue
+		}
+		list = append(list, key.PrivateKey)
+	}
+	return list
+}
+
+// keystorePassphrase returns the keystore passphrase from the password file,
+// the environment or an interactive prompt, in that order.
+func keystorePassphrase(passwordFile string) (string, error) {
+	if passwordFile != "" {
+		data, err := ioutil.ReadFile(passwordFile)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if pass, ok := os.LookupEnv(passwordEnv); ok {
+		return pass, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !terminal.IsTerminal(fd) {
+		return "", errors.New("no passphrase given and stdin is not a terminal")
+	}
+	// the prompt goes to stderr, stdout may be piped to a script
+	fmt.Fprint(os.Stderr, "Keystore passphrase: ")
+	pass, err := terminal.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(pass), nil
+}
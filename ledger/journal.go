@@ -0,0 +1,92 @@
+package ledger
+
+import (
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var journalBucket = []byte("journal")
+
+// Entry is a cashCheque transaction that was signed for broadcasting but is
+// not known to be final yet. It is keyed by address and cumulative payout,
+// since the contract only ever pays a given cheque once.
+type Entry struct {
+	Address          string   `json:"address"`
+	CumulativePayout *big.Int `json:"cumulative_payout"`
+	TxHash           string   `json:"tx_hash"`
+	// Replaced are the hashes of earlier transactions with the same nonce
+	// that TxHash replaced with a higher gas price.
+	Replaced []string `json:"replaced,omitempty"`
+	// CancelTx is the hash of a self transfer sent to cancel the cashout.
+	CancelTx  string    `json:"cancel_tx,omitempty"`
+	AttemptID uint64    `json:"attempt_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Hashes returns every transaction hash that may settle the entry, the
+// latest first.
+func (e *Entry) Hashes() []string {
+	hashes := []string{e.TxHash}
+	if e.CancelTx != "" {
+		hashes = []string{e.CancelTx, e.TxHash}
+	}
+	for i := len(e.Replaced) - 1; i >= 0; i-- {
+		hashes = append(hashes, e.Replaced[i])
+	}
+	return hashes
+}
+
+// Replace records that tx replaced the current transaction of e.
+func (e *Entry) Replace(txHash string) {
+	e.Replaced = append(e.Replaced, e.TxHash)
+	e.TxHash = txHash
+}
+
+func entryKey(address string, cumulativePayout *big.Int) []byte {
+	return []byte(strings.ToLower(address) + ":" + cumulativePayout.String())
+}
+
+// Journal writes e ahead of broadcasting its transaction. Writing an
+// existing entry again updates it.
+func (l *Ledger) Journal(e *Entry) error {
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(journalBucket).Put(entryKey(e.Address, e.CumulativePayout), data)
+	})
+}
+
+// Resolve removes the journal entry once its transaction is final or known
+// to be dropped.
+func (l *Ledger) Resolve(address string, cumulativePayout *big.Int) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(journalBucket).Delete(entryKey(address, cumulativePayout))
+	})
+}
+
+// Pending returns the unresolved journal entries. An empty address returns
+// the entries of every address.
+func (l *Ledger) Pending(address string) ([]*Entry, error) {
+	var list []*Entry
+	err := l.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(journalBucket).Cursor()
+		prefix := []byte(strings.ToLower(address))
+		for k, v := c.Seek(prefix); k != nil && strings.HasPrefix(string(k), string(prefix)); k, v = c.Next() {
+			e := new(Entry)
+			if err := json.Unmarshal(v, e); err != nil {
+				return err
+			}
+			list = append(list, e)
+		}
+		return nil
+	})
+	return list, err
+}
@@ -0,0 +1,127 @@
+package ledger
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	bolt "go.etcd.io/bbolt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusPending Status = "pending"
+	StatusSuccess Status = "success"
+	StatusFailed  Status = "failed"
+	StatusSkipped Status = "skipped"
+)
+
+var attemptsBucket = []byte("attempts")
+
+// Attempt is one try to cash out a cheque for an address.
+type Attempt struct {
+	ID      uint64   `json:"id"`
+	Address string   `json:"address"`
+	Amount  *big.Int `json:"amount"`
+	// PaidOut is the on-chain paidOut of the address before the attempt.
+	PaidOut   *big.Int  `json:"paid_out"`
+	TxHash    string    `json:"tx_hash,omitempty"`
+	GasUsed   uint64    `json:"gas_used,omitempty"`
+	Status    Status    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Ledger is an embedded BoltDB database of cashout attempts.
+type Ledger struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Ledger, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{attemptsBucket, journalBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Ledger{db: db}, nil
+}
+
+func (l *Ledger) Close() error {
+	return l.db.Close()
+}
+
+// Record stores a. A new attempt gets the next ID and its creation time; an
+// existing one is overwritten.
+func (l *Ledger) Record(a *Attempt) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(attemptsBucket)
+		now := time.Now()
+		if a.ID == 0 {
+			id, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			a.ID = id
+			a.CreatedAt = now
+		}
+		a.UpdatedAt = now
+		data, err := json.Marshal(a)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(a.ID), data)
+	})
+}
+
+// Attempt returns the attempt with id, or nil if there is none.
+func (l *Ledger) Attempt(id uint64) (*Attempt, error) {
+	var a *Attempt
+	err := l.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(attemptsBucket).Get(itob(id))
+		if v == nil {
+			return nil
+		}
+		a = new(Attempt)
+		return json.Unmarshal(v, a)
+	})
+	return a, err
+}
+
+// Attempts returns the recorded attempts in order. An empty address returns
+// the attempts of every address.
+func (l *Ledger) Attempts(address string) ([]*Attempt, error) {
+	var list []*Attempt
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(attemptsBucket).ForEach(func(k, v []byte) error {
+			a := new(Attempt)
+			if err := json.Unmarshal(v, a); err != nil {
+				return err
+			}
+			if address == "" || strings.EqualFold(a.Address, address) {
+				list = append(list, a)
+			}
+			return nil
+		})
+	})
+	return list, err
+}
+
+func itob(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
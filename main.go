@@ -1,124 +1,72 @@
 package main
 
 import (
-	"encoding/hex"
-	"encoding/json"
 	"flag"
 	"fmt"
-	"github.com/ethereum/go-ethereum/crypto"
-	crypto2 "github.com/ethersphere/bee/pkg/crypto"
-	"github.com/zhaozilong88/cashout/eth"
-	"io/ioutil"
-	"math/big"
-	"net/http"
-	"strings"
+	"os"
 )
 
-var conf = eth.Config{
-	Network:         "https://bsc-dataseed.binance.org",
-	ContractAddress: "0x5E772AcF0F20b0315391021e0884cb1F1Aa4545C",
-	GasLimit:        5718749,
-}
-
 var (
-	keyFile   = flag.String("key_file", "key.txt", "key file")
-	gasPrice  = flag.Int64("gas_price", 5, "gas price Gwei")
-	gasLimit  = flag.Uint64("gas_limit", 100000, "gas limit")
-	minPayOut = flag.Int64("min_pay_out", 10000, "min pay out")
+	cfg        = defaultConfig()
+	configFile = flag.String("config", "", "YAML configuration file (default $"+envPrefix+"CONFIG)")
 )
 
-func readKeys(filename string) []string {
-	var list []string
-	data, err := ioutil.ReadFile(filename)
-	if err != nil {
-		fmt.Printf("failed to read file, %v", err)
-		return list
-	}
-	ss := strings.Split(string(data), "\n")
-	for _, str := range ss {
-		s := strings.TrimSpace(str)
-		if len(s) == len("77dd33ed201813038b5c9a33b9eb0d4a07c3b83bd88e709e40228b762feedecd") {
-			list = append(list, s)
-		}
-	}
-	return list
+func init() {
+	bindFlags(flag.CommandLine, &cfg)
 }
 
-func handleKeys(contract *eth.Contract, keys []string, minPayOut int64) {
-	for _, key := range keys {
-		prvKey, err := crypto.HexToECDSA(key)
-		if err != nil {
-			fmt.Printf("key error: %v\n", key)
-			continue
-		}
-		singer := crypto2.NewDefaultSigner(prvKey)
-		addr, err := singer.EthereumAddress()
-		if err != nil {
-			fmt.Printf("key error: %v\n", key)
-			continue
-		}
-
-		amount, sign := getCheque(addr.String())
-		reward := big.NewInt(amount)
-		paidOut, err := contract.GetPaidOut(addr.String())
-
-		//fmt.Printf("%v %v %v\n", addr.String(), reward.String(), paidOut.String())
-		a := big.NewInt(0).Add(paidOut, big.NewInt(minPayOut*10000))
-		if reward.Cmp(a) > 0 {
-			hexSign, _ := hex.DecodeString(sign)
-			_, err := contract.Cashout(prvKey, reward, hexSign)
-			if err == nil && len(hexSign) > 0 {
-				b := big.NewInt(0).Sub(reward, paidOut)
-				fmt.Printf("%s %g\n", addr.String(), float64(b.Int64())/10000)
-			}
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	name := "cashout"
+	if flag.NArg() > 0 {
+		name = flag.Arg(0)
+		// flags may also follow the command
+		if err := flag.CommandLine.Parse(flag.Args()[1:]); err != nil {
+			return
 		}
 	}
-}
-
-func getCheque(address string) (int64, string) {
-	// https://api.gpfs.xyz/v1/cheque?address=0x664e01fc0f9a5dc2e814af517dce25071525544f
-	// {"code":0,"msg":"success","data":{"amount":2365437062,"paid_out":2066895147,"signature":"2b6fb4d75dbc1ef6f61d941fddbf9a49c6162cc7fea374e1528146088962683d409d83c2191becf367ce14915fff1ab404f1b86b67b2c05fd3482b4d61f1d46b1b"}}
-
-	res, err := http.Get("https://api.gpfs.xyz/v1/cheque?address=" + strings.ToLower(address))
-	if err != nil {
-		fmt.Printf("failed to get cheque : %v", err)
-		return 0, ""
+	cmd := lookupCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		flag.Usage()
+		os.Exit(2)
 	}
-	defer res.Body.Close()
-	data, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		fmt.Printf("failed to get cheque : %v", err)
-		return 0, ""
+	if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "unexpected arguments %v\n", flag.Args())
+		flag.Usage()
+		os.Exit(2)
 	}
-	//fmt.Printf("%v\n", string(data))
-
-	type Data struct {
-		Amount    int64  `json:"amount"`
-		Signature string `json:"signature"`
+	if err := run(cmd); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
-	ret := struct {
-		Code int  `json:"code"`
-		D    Data `json:"data"`
-	}{}
-	json.Unmarshal(data, &ret)
-	return ret.D.Amount, ret.D.Signature
 }
 
-func main() {
-	flag.Parse()
-
-	keys := readKeys(*keyFile)
-	if len(keys) == 0 {
-		fmt.Printf("no key in file\n")
-		return
+// run sets up the app for cmd and runs it, so scripts can tell from the exit
+// code whether it failed.
+func run(cmd *command) error {
+	if err := loadConfig(flag.CommandLine, &cfg, *configFile, cmd.contract); err != nil {
+		return err
 	}
-	//fmt.Printf("keys: %v\n", keys)
-	conf.GasLimit = *gasLimit
-	conf.GasPrice = *gasPrice
 
-	contract, err := eth.NewContract(conf)
+	a, err := newApp(cmd, cfg)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	defer a.close()
+
+	ctx, cancel := signalContext(a.messages())
+	defer cancel()
+	return cmd.run(ctx, a)
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-8s %s\n", c.name, c.summary)
 	}
-	handleKeys(contract, keys, *minPayOut)
+	fmt.Fprintf(out, "\nFlags:\n")
+	flag.PrintDefaults()
 }
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/zhaozilong88/cashout/cashout"
+	"github.com/zhaozilong88/cashout/eth"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Output formats of the commands.
+const (
+	outputText = "text"
+	outputJSON = "json"
+	outputCSV  = "csv"
+)
+
+// reportHeader heads the table a dry run prints, one row per address.
+const reportHeader = "address\tcheque\tpaid out\tclaimable\tmin pay out\tgas cost\tbalance\terror\t"
+
+// printer writes the results of cashout runs in an output format.
+type printer struct {
+	output   string
+	decimals int
+	dryRun   bool
+	header   sync.Once
+}
+
+// summary counts the results of a run.
+type summary struct {
+	statuses  map[cashout.Status]int
+	cashed    *big.Int
+	gasUsed   uint64
+	endpoints []eth.EndpointStats
+}
+
+func newSummary() *summary {
+	return &summary{statuses: map[cashout.Status]int{}, cashed: new(big.Int)}
+}
+
+func (s *summary) add(r *cashout.Result) {
+	s.statuses[r.Status]++
+	if r.Cashed != nil {
+		s.cashed.Add(s.cashed, r.Cashed)
+	}
+	s.gasUsed += r.GasUsed
+}
+
+// csvHeader names the columns of the CSV output. The summary record puts the
+// status counts into the status column and the RPC endpoint counters into
+// the endpoints column.
+var csvHeader = []string{"type", "address", "status", "cheque", "paid_out", "claimable", "cashed", "tx_hash", "gas_used", "gas_cost", "balance", "error", "endpoints"}
+
+type jsonResult struct {
+	Type      string `json:"type"`
+	Address   string `json:"address"`
+	Status    string `json:"status"`
+	Cheque    string `json:"cheque,omitempty"`
+	PaidOut   string `json:"paid_out,omitempty"`
+	Claimable string `json:"claimable,omitempty"`
+	Cashed    string `json:"cashed,omitempty"`
+	TxHash    string `json:"tx_hash,omitempty"`
+	GasUsed   uint64 `json:"gas_used,omitempty"`
+	GasCost   string `json:"gas_cost,omitempty"`
+	Balance   string `json:"balance,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type jsonSummary struct {
+	Type      string                 `json:"type"`
+	Addresses int                    `json:"addresses"`
+	Statuses  map[cashout.Status]int `json:"statuses"`
+	Cashed    string                 `json:"cashed"`
+	GasUsed   uint64                 `json:"gas_used"`
+	Endpoints []jsonEndpoint         `json:"endpoints,omitempty"`
+}
+
+type jsonEndpoint struct {
+	URL     string `json:"url"`
+	Healthy bool   `json:"healthy"`
+	Calls   uint64 `json:"calls"`
+	Errors  uint64 `json:"errors"`
+}
+
+// messageWriter returns where progress and error messages go: out for text
+// output, stderr otherwise so out only carries records.
+func messageWriter(output string, out io.Writer) io.Writer {
+	if output == outputText {
+		return out
+	}
+	return os.Stderr
+}
+
+// writeHeader writes the CSV header, once per process.
+func (p *printer) writeHeader(out io.Writer) {
+	if p.output == outputCSV {
+		p.header.Do(func() { writeCSV(out, csvHeader) })
+	}
+}
+
+// emit writes the record of r. Text output prints a line for cashouts that
+// were done, skipped or failed and a table row for dry runs.
+func (p *printer) emit(out io.Writer, r *cashout.Result) {
+	var txHash, errMsg string
+	if r.TxHash != (common.Hash{}) {
+		txHash = r.TxHash.Hex()
+	}
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	switch p.output {
+	case outputJSON:
+		writeJSON(out, jsonResult{
+			Type:      "result",
+			Address:   r.Address.String(),
+			Status:    string(r.Status),
+			Cheque:    p.decimal(r.Cheque),
+			PaidOut:   p.decimal(r.PaidOut),
+			Claimable: p.decimal(r.Claimable),
+			Cashed:    p.decimal(r.Cashed),
+			TxHash:    txHash,
+			GasUsed:   r.GasUsed,
+			GasCost:   weiDecimal(r.GasCost),
+			Balance:   weiDecimal(r.Balance),
+			Error:     errMsg,
+		})
+	case outputCSV:
+		gasUsed := ""
+		if r.GasUsed > 0 {
+			gasUsed = strconv.FormatUint(r.GasUsed, 10)
+		}
+		writeCSV(out, []string{"result", r.Address.String(), string(r.Status), p.decimal(r.Cheque), p.decimal(r.PaidOut), p.decimal(r.Claimable), p.decimal(r.Cashed), txHash, gasUsed, weiDecimal(r.GasCost), weiDecimal(r.Balance), errMsg, ""})
+	default:
+		if p.dryRun {
+			if r.Cheque != nil && r.PaidOut != nil {
+				fmt.Fprint(out, p.reportRow(r))
+			} else if r.Err != nil {
+				fmt.Fprintf(out, "%s %v\n", r.Address.String(), r.Err)
+			}
+			return
+		}
+		switch {
+		case r.Status == cashout.StatusSuccess:
+			fmt.Fprintf(out, "%s %s\n", r.Address.String(), p.decimal(r.Cashed))
+		case r.Status == cashout.StatusSkipped:
+			fmt.Fprintf(out, "skip %s, %v\n", r.Address.String(), r.Err)
+		case r.Err != nil && txHash != "":
+			fmt.Fprintf(out, "%s cashout %s failed, %v\n", r.Address.String(), txHash, r.Err)
+		case r.Err != nil:
+			fmt.Fprintf(out, "%s %v\n", r.Address.String(), r.Err)
+		}
+	}
+}
+
+// reportRow formats r as a row of the dry run table.
+func (p *printer) reportRow(r *cashout.Result) string {
+	meets := "no"
+	if r.Status == cashout.StatusWouldCashOut {
+		meets = "yes"
+	}
+	gasCost, balance, errMsg := "-", "-", ""
+	if r.GasCost != nil {
+		gasCost = formatEther(r.GasCost)
+	}
+	if r.Balance != nil {
+		balance = formatEther(r.Balance)
+	}
+	if r.Err != nil {
+		errMsg = r.Err.Error()
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n", r.Address.String(), p.decimal(r.Cheque), p.decimal(r.PaidOut), p.decimal(r.Claimable), meets, gasCost, balance, errMsg)
+}
+
+// emitSummary writes the summary record of a run.
+func (p *printer) emitSummary(out io.Writer, s *summary) {
+	var addresses int
+	var counts []string
+	for status, n := range s.statuses {
+		addresses += n
+		counts = append(counts, fmt.Sprintf("%s=%d", status, n))
+	}
+	sort.Strings(counts)
+	var endpoints []jsonEndpoint
+	var endpointCounts []string
+	for _, e := range s.endpoints {
+		endpoints = append(endpoints, jsonEndpoint{URL: e.URL, Healthy: e.Healthy, Calls: e.Calls, Errors: e.Errors})
+		endpointCounts = append(endpointCounts, fmt.Sprintf("%s calls=%d errors=%d", e.URL, e.Calls, e.Errors))
+	}
+
+	switch p.output {
+	case outputJSON:
+		writeJSON(out, jsonSummary{
+			Type:      "summary",
+			Addresses: addresses,
+			Statuses:  s.statuses,
+			Cashed:    p.decimal(s.cashed),
+			GasUsed:   s.gasUsed,
+			Endpoints: endpoints,
+		})
+	case outputCSV:
+		writeCSV(out, []string{"summary", "", strings.Join(counts, " "), "", "", "", p.decimal(s.cashed), "", strconv.FormatUint(s.gasUsed, 10), "", "", "", strings.Join(endpointCounts, "; ")})
+	}
+}
+
+// decimal formats an amount in smallest units as an exact decimal string of
+// whole tokens.
+func (p *printer) decimal(amount *big.Int) string {
+	if amount == nil {
+		return ""
+	}
+	return cashout.FormatAmount(amount, p.decimals)
+}
+
+// weiDecimal formats an amount of wei as an exact decimal string of BNB.
+func weiDecimal(wei *big.Int) string {
+	if wei == nil {
+		return ""
+	}
+	return cashout.FormatAmount(wei, etherDecimals)
+}
+
+func writeJSON(out io.Writer, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode record, %v\n", err)
+		return
+	}
+	out.Write(append(data, '\n'))
+}
+
+func writeCSV(out io.Writer, record []string) {
+	w := csv.NewWriter(out)
+	w.Write(record)
+	w.Flush()
+}
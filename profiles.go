@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"github.com/zhaozilong88/cashout/cheque"
+	"sort"
+	"strings"
+)
+
+// profile bundles the settings of one chain and GPSToken deployment.
+type profile struct {
+	Endpoints       []string `yaml:"endpoints"`
+	ChainID         uint64   `yaml:"chain_id"`
+	ContractAddress string   `yaml:"contract_address"`
+	ChequeAPI       string   `yaml:"cheque_api"`
+	// Decimals pins the token decimals of the deployment; nil or -1 reads
+	// them from the contract.
+	Decimals *int `yaml:"decimals"`
+}
+
+const defaultProfile = "bsc-mainnet"
+
+// profiles are the built in network profiles. Deployments without a known
+// contract or cheque API leave them empty, they have to be configured.
+var profiles = map[string]profile{
+	"bsc-mainnet": {
+		Endpoints: []string{
+			"https://bsc-dataseed.binance.org",
+			"https://bsc-dataseed1.defibit.io",
+			"https://bsc-dataseed1.ninicoin.io",
+		},
+		ChainID:         56,
+		ContractAddress: "0x5E772AcF0F20b0315391021e0884cb1F1Aa4545C",
+		ChequeAPI:       cheque.DefaultURL,
+	},
+	"bsc-testnet": {
+		Endpoints: []string{
+			"https://data-seed-prebsc-1-s1.binance.org:8545",
+			"https://data-seed-prebsc-2-s1.binance.org:8545",
+		},
+		ChainID: 97,
+	},
+	"local-dev": {
+		Endpoints: []string{"http://127.0.0.1:8545"},
+		ChainID:   1337,
+		ChequeAPI: "http://127.0.0.1:8080",
+	},
+}
+
+// lookupProfile finds a profile by name, preferring the ones defined in the
+// configuration file over the built in ones.
+func lookupProfile(name string, custom map[string]profile) (profile, error) {
+	if p, ok := custom[name]; ok {
+		return p, nil
+	}
+	if p, ok := profiles[name]; ok {
+		return p, nil
+	}
+	var names []string
+	for n := range profiles {
+		names = append(names, n)
+	}
+	for n := range custom {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return profile{}, fmt.Errorf("unknown profile %q, available: %s", name, strings.Join(names, ", "))
+}
+
+// own overrides the settings p defines, leaving the ones it leaves empty as
+// configured.
+func (p profile) own(c *config) {
+	if len(p.Endpoints) > 0 {
+		c.Eth.Network = p.Endpoints[0]
+		c.Eth.Endpoints = p.Endpoints
+	}
+	if p.ChainID != 0 {
+		c.Eth.ChainID = p.ChainID
+	}
+	if p.ContractAddress != "" {
+		c.Eth.ContractAddress = p.ContractAddress
+	}
+	if p.ChequeAPI != "" {
+		c.Cheque.API = p.ChequeAPI
+	}
+	if p.Decimals != nil {
+		c.Decimals = *p.Decimals
+	}
+}
+
+// apply replaces the network settings of c with the ones of p.
+func (p profile) apply(c *config) {
+	c.Eth.Network = ""
+	if len(p.Endpoints) > 0 {
+		c.Eth.Network = p.Endpoints[0]
+	}
+	c.Eth.Endpoints = p.Endpoints
+	c.Eth.ChainID = p.ChainID
+	c.Eth.ContractAddress = p.ContractAddress
+	c.Cheque.API = p.ChequeAPI
+	c.Decimals = -1
+	if p.Decimals != nil {
+		c.Decimals = *p.Decimals
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// table writes rows in an output format: aligned text, one JSON object per
+// row keyed by column, or CSV with a header.
+type table struct {
+	format  string
+	columns []string
+	// out receives the rows, for text it is a tabwriter over the output
+	out io.Writer
+	tw  *tabwriter.Writer
+	// vertical prints a text table as one "column value" line per cell, for
+	// tables of a single row
+	vertical bool
+}
+
+func newTable(format string, out io.Writer, vertical bool, columns ...string) *table {
+	t := &table{format: format, columns: columns, out: out, vertical: vertical}
+	switch format {
+	case outputJSON:
+	case outputCSV:
+		writeCSV(out, columns)
+	default:
+		t.tw = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		t.out = t.tw
+		if !vertical {
+			fmt.Fprintf(t.tw, "%s\t\n", strings.Join(columns, "\t"))
+		}
+	}
+	return t
+}
+
+// line formats a row. It is written to t.out, directly or through a buffer
+// that is copied there.
+func (t *table) line(values ...string) []byte {
+	switch t.format {
+	case outputJSON:
+		var b strings.Builder
+		m := make(map[string]string, len(values))
+		for i, v := range values {
+			m[t.columns[i]] = v
+		}
+		writeJSON(&b, m)
+		return []byte(b.String())
+	case outputCSV:
+		var b strings.Builder
+		w := csv.NewWriter(&b)
+		w.Write(values)
+		w.Flush()
+		return []byte(b.String())
+	}
+	if t.vertical {
+		var b strings.Builder
+		for i, v := range values {
+			fmt.Fprintf(&b, "%s\t%s\t\n", t.columns[i], v)
+		}
+		return []byte(b.String())
+	}
+	return []byte(strings.Join(values, "\t") + "\t\n")
+}
+
+func (t *table) row(values ...string) {
+	t.out.Write(t.line(values...))
+}
+
+func (t *table) flush() {
+	if t.tw != nil {
+		t.tw.Flush()
+	}
+}